@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"taskmanager/auth"
+	"taskmanager/config"
+	"taskmanager/logging"
+	"taskmanager/models"
+	"taskmanager/repository"
+	"taskmanager/service"
+)
+
+// runCreateAdmin bootstraps an admin account, since admins cannot be
+// created through the public API.
+func runCreateAdmin(args []string) error {
+	fs := flag.NewFlagSet("taskmanager create-admin", flag.ContinueOnError)
+	name := fs.String("name", "", "admin display name")
+	email := fs.String("email", "", "admin email address")
+	// The environment is read after parsing, so usage output never shows
+	// the password as the flag's default.
+	password := fs.String("password", "", "admin password (default $TASKMANAGER_ADMIN_PASSWORD)")
+
+	cfg, err := config.LoadFlags(fs, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if *password == "" {
+		*password = os.Getenv("TASKMANAGER_ADMIN_PASSWORD")
+	}
+	if cfg.Storage == repository.BackendMemory {
+		return errors.New("create-admin needs a SQL storage backend")
+	}
+
+	// Logs go to stderr so stdout carries only the API key.
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	db, repos, err := openStorage(cfg, logger, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := auth.WithPrincipal(context.Background(), auth.System)
+	admin := models.User{Name: *name, Email: *email, Role: auth.RoleAdmin}
+	if err := service.NewUserService(repos.Users, repos.Tasks, repos.Sessions, repos.Audit, repos.Tx, logger).CreateUser(ctx, &admin, *password); err != nil {
+		return err
+	}
+
+	key, err := service.NewAuthService(repos.Users, repos.APIKeys, repos.Sessions, repos.Tx, nil, logger).IssueAPIKey(ctx, admin.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created admin %d. API key (shown once): %s\n", admin.ID, key)
+	return nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Passwords are hashed with argon2id and stored in the PHC string format,
+// "$argon2id$v=19$m=<KiB>,t=<passes>,p=<lanes>$<salt>$<key>". The cost
+// parameters are kept in the hash so they can be raised later without
+// invalidating stored hashes.
+const (
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+var ErrMalformedHash = errors.New("malformed password hash")
+
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, keyLength)
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches encoded, comparing in
+// constant time.
+func CheckPassword(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" || parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return false, ErrMalformedHash
+	}
+	var memory, passes uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil || passes == 0 || threads == 0 {
+		return false, ErrMalformedHash
+	}
+	salt, want, err := decodeSaltAndKey(parts[4], parts[5])
+	if err != nil {
+		return false, err
+	}
+
+	got := argon2.IDKey([]byte(password), salt, passes, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+func decodeSaltAndKey(salt, key string) ([]byte, []byte, error) {
+	enc := base64.RawStdEncoding
+	s, err := enc.DecodeString(salt)
+	if err != nil {
+		return nil, nil, ErrMalformedHash
+	}
+	k, err := enc.DecodeString(key)
+	if err != nil || len(k) == 0 {
+		return nil, nil, ErrMalformedHash
+	}
+	return s, k, nil
+}
+
+// dummyHash lets callers spend the same time on unknown accounts as on
+// wrong passwords, so login timing does not reveal which emails exist.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("taskmanager-dummy-password")
+	return hash
+})
+
+func CheckDummyPassword(password string) {
+	CheckPassword(dummyHash(), password)
+}
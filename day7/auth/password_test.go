@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashAndCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") || strings.Contains(hash, "horse") {
+		t.Errorf("Unexpected hash format: %s", hash)
+	}
+
+	if ok, err := CheckPassword(hash, "correct horse battery staple"); err != nil || !ok {
+		t.Errorf("Expected the password to match, got %v, %v", ok, err)
+	}
+	if ok, _ := CheckPassword(hash, "Correct horse battery staple"); ok {
+		t.Error("Expected a different password not to match")
+	}
+
+	again, _ := HashPassword("correct horse battery staple")
+	if again == hash {
+		t.Error("Expected a fresh salt for every hash")
+	}
+}
+
+func TestCheckPasswordRejectsMalformedHash(t *testing.T) {
+	for _, hash := range []string{"", "plain", "md5$1$a$b", "pbkdf2-sha256$x$a$b", "$argon2id$v=19$m=1,t=0,p=1$c2FsdA$a2V5", "$argon2id$v=16$m=1,t=1,p=1$c2FsdA$a2V5"} {
+		if _, err := CheckPassword(hash, "pw"); err == nil {
+			t.Errorf("Expected an error for %q", hash)
+		}
+	}
+}
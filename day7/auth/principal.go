@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal is the authenticated caller a request acts on behalf of.
+type Principal struct {
+	UserID    int
+	Role      string
+	SessionID string
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// System acts for the process itself, e.g. CLI commands and background
+// jobs, and is allowed everything an admin is.
+var System = Principal{Role: RoleAdmin}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
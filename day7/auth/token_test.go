@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner([]byte("test-secret"), time.Hour)
+
+	token, expires, err := signer.Sign(Principal{UserID: 7, Role: RoleAdmin, SessionID: "abc"})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if time.Until(expires) > time.Hour {
+		t.Errorf("Unexpected expiry %v", expires)
+	}
+
+	p, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if p.UserID != 7 || !p.IsAdmin() || p.SessionID != "abc" {
+		t.Errorf("Unexpected principal %+v", p)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	signer := NewSigner([]byte("test-secret"), time.Hour)
+	token, _, _ := signer.Sign(Principal{UserID: 7, Role: RoleUser})
+
+	other := NewSigner([]byte("other-secret"), time.Hour)
+	if _, err := other.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token signed with another secret to fail, got %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged, _, _ := signer.Sign(Principal{UserID: 1, Role: RoleAdmin})
+	parts[1] = strings.Split(forged, ".")[1]
+	if _, err := signer.Verify(strings.Join(parts, ".")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a swapped payload to fail, got %v", err)
+	}
+}
+
+func TestVerifyRejectsExpired(t *testing.T) {
+	signer := NewSigner([]byte("test-secret"), time.Minute)
+	token, _, _ := signer.Sign(Principal{UserID: 7, Role: RoleUser})
+
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := signer.Verify(token); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("Expected ErrExpiredToken, got %v", err)
+	}
+}
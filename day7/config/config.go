@@ -0,0 +1,404 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+type Config struct {
+	HTTP    HTTPConfig  `json:"http"`
+	Storage string      `json:"storage"`
+	DB      DBConfig    `json:"db"`
+	Auth    AuthConfig  `json:"auth"`
+	Log     LogConfig   `json:"log"`
+	Trace   TraceConfig `json:"trace"`
+	// RateLimit caps requests per client on each authenticated or public
+	// API route.
+	RateLimit RateLimitConfig `json:"rate_limit"`
+	Trash     TrashConfig     `json:"trash"`
+}
+
+type HTTPConfig struct {
+	Addr            string              `json:"addr"`
+	ReadTimeout     Duration            `json:"read_timeout"`
+	WriteTimeout    Duration            `json:"write_timeout"`
+	IdleTimeout     Duration            `json:"idle_timeout"`
+	ShutdownTimeout Duration            `json:"shutdown_timeout"`
+	ShutdownDelay   Duration            `json:"shutdown_delay"`
+	ReadyTimeout    Duration            `json:"ready_timeout"`
+	RequestTimeout  Duration            `json:"request_timeout"`
+	RouteTimeouts   map[string]Duration `json:"route_timeouts"`
+	MaxBodyBytes    int                 `json:"max_body_bytes"`
+	CORSOrigins     []string            `json:"cors_origins"`
+	// IdempotencyTTL is how long the response to a request carrying an
+	// Idempotency-Key is kept for replay; zero ignores the header.
+	IdempotencyTTL Duration `json:"idempotency_ttl"`
+}
+
+// TimeoutFor returns the deadline for a ServeMux pattern such as
+// "GET /tasks/{id}", falling back to the global request timeout.
+func (c HTTPConfig) TimeoutFor(pattern string) time.Duration {
+	if d, ok := c.RouteTimeouts[pattern]; ok {
+		return d.Std()
+	}
+	return c.RequestTimeout.Std()
+}
+
+// fitsWriteTimeout reports whether a handler deadline leaves time to write
+// the timeout response before the server cuts the connection.
+func (c HTTPConfig) fitsWriteTimeout(d Duration) bool {
+	return c.WriteTimeout == 0 || d == 0 || d < c.WriteTimeout
+}
+
+type DBConfig struct {
+	DSN             Secret   `json:"dsn"`
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+	AutoMigrate     bool     `json:"auto_migrate"`
+	// TxRetries is how many times a transaction aborted by a deadlock is
+	// run again.
+	TxRetries int `json:"tx_retries"`
+}
+
+type AuthConfig struct {
+	TokenSecret Secret   `json:"token_secret"`
+	TokenTTL    Duration `json:"token_ttl"`
+}
+
+type LogConfig struct {
+	Format string `json:"format"`
+	Level  string `json:"level"`
+	// SlowQuery is the duration above which SQL statements are logged as
+	// warnings; zero disables slow-query logging.
+	SlowQuery Duration `json:"slow_query"`
+}
+
+type TraceConfig struct {
+	// Exporter is where finished spans go: none, stdout or file.
+	Exporter string `json:"exporter"`
+	// File is the path spans are appended to by the file exporter.
+	File string `json:"file"`
+}
+
+type RateLimitConfig struct {
+	// Default applies to routes without an entry in Routes. A zero rate
+	// disables limiting.
+	Default Rate            `json:"default"`
+	Routes  map[string]Rate `json:"routes"`
+	// PerIP caps the requests from each remote address on every route
+	// before authentication, so bad credentials are limited too. Clients
+	// behind one address share it, so it should be well above Default.
+	PerIP Rate `json:"per_ip"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted tasks and users can be restored before
+	// they are purged for good; zero keeps them forever.
+	Retention Duration `json:"retention"`
+}
+
+// RateFor returns the limit for a ServeMux pattern such as "POST /tasks".
+func (c RateLimitConfig) RateFor(pattern string) Rate {
+	if r, ok := c.Routes[pattern]; ok {
+		return r
+	}
+	return c.Default
+}
+
+// minSecretLength matches the HMAC-SHA256 key size.
+const minSecretLength = 32
+
+const (
+	envPrefix  = "TASKMANAGER_"
+	envConfig  = envPrefix + "CONFIG"
+	flagConfig = "config"
+)
+
+var storageBackends = []string{"memory", "mysql", "postgres", "sqlite"}
+
+var traceExporters = []string{"none", "stdout", "file"}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			ReadTimeout:     Duration(5 * time.Second),
+			WriteTimeout:    Duration(10 * time.Second),
+			IdleTimeout:     Duration(120 * time.Second),
+			ShutdownTimeout: Duration(15 * time.Second),
+			ReadyTimeout:    Duration(2 * time.Second),
+			// Shorter than the write timeout so a timed-out handler can
+			// still send its 504.
+			RequestTimeout: Duration(8 * time.Second),
+			MaxBodyBytes:   1 << 20,
+			IdempotencyTTL: Duration(24 * time.Hour),
+		},
+		Storage: "mysql",
+		DB: DBConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration(5 * time.Minute),
+			ConnMaxIdleTime: Duration(time.Minute),
+			TxRetries:       3,
+		},
+		Auth: AuthConfig{
+			TokenTTL: Duration(time.Hour),
+		},
+		Log: LogConfig{
+			Format:    "json",
+			Level:     "info",
+			SlowQuery: Duration(200 * time.Millisecond),
+		},
+		Trace: TraceConfig{
+			Exporter: "none",
+		},
+		RateLimit: RateLimitConfig{
+			Default: Rate{Requests: 300, Per: Duration(time.Minute)},
+			Routes: map[string]Rate{
+				"POST /auth/login": {Requests: 10, Per: Duration(time.Minute)},
+				"POST /users":      {Requests: 10, Per: Duration(time.Minute)},
+				"POST /tasks":      {Requests: 60, Per: Duration(time.Minute)},
+			},
+			PerIP: Rate{Requests: 1200, Per: Duration(time.Minute)},
+		},
+		Trash: TrashConfig{
+			Retention: Duration(30 * 24 * time.Hour),
+		},
+	}
+}
+
+type setting struct {
+	env, flag, usage string
+	value            value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"HTTP_ADDR", "addr", "HTTP listen address", stringValue{&c.HTTP.Addr}},
+		{"HTTP_READ_TIMEOUT", "read-timeout", "maximum time to read a request, body included (0 = none)", durationValue{&c.HTTP.ReadTimeout}},
+		{"HTTP_WRITE_TIMEOUT", "write-timeout", "maximum time to write a response (0 = none)", durationValue{&c.HTTP.WriteTimeout}},
+		{"HTTP_IDLE_TIMEOUT", "idle-timeout", "how long keep-alive connections wait for the next request", durationValue{&c.HTTP.IdleTimeout}},
+		{"HTTP_SHUTDOWN_TIMEOUT", "shutdown-timeout", "grace period for in-flight requests and workers on shutdown", durationValue{&c.HTTP.ShutdownTimeout}},
+		{"HTTP_SHUTDOWN_DELAY", "shutdown-delay", "how long /readyz fails before draining starts on shutdown", durationValue{&c.HTTP.ShutdownDelay}},
+		{"HTTP_READY_TIMEOUT", "ready-timeout", "deadline for each /readyz dependency check", durationValue{&c.HTTP.ReadyTimeout}},
+		{"HTTP_REQUEST_TIMEOUT", "request-timeout", "default deadline for handling a request (0 = none)", durationValue{&c.HTTP.RequestTimeout}},
+		{"HTTP_ROUTE_TIMEOUTS", "route-timeouts", `per-route deadlines, e.g. "GET /tasks/{id}=2s,POST /tasks=5s"`, durationMapValue{&c.HTTP.RouteTimeouts}},
+		{"HTTP_MAX_BODY_BYTES", "max-body-bytes", "largest accepted request body in bytes (0 = unlimited)", intValue{&c.HTTP.MaxBodyBytes}},
+		{"HTTP_CORS_ORIGINS", "cors-origins", `comma-separated origins allowed by CORS, or "*" (empty = CORS off)`, stringListValue{&c.HTTP.CORSOrigins}},
+		{"HTTP_IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses are kept for replay to retries with the same Idempotency-Key (0 = off)", durationValue{&c.HTTP.IdempotencyTTL}},
+		{"STORAGE", "storage", "storage backend: memory, mysql, postgres or sqlite", stringValue{&c.Storage}},
+		{"DB_DSN", "dsn", "database connection string for SQL backends", secretValue{&c.DB.DSN}},
+		{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections (0 = unlimited)", intValue{&c.DB.MaxOpenConns}},
+		{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", intValue{&c.DB.MaxIdleConns}},
+		{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum lifetime of a database connection", durationValue{&c.DB.ConnMaxLifetime}},
+		{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum idle time of a database connection", durationValue{&c.DB.ConnMaxIdleTime}},
+		{"AUTH_TOKEN_SECRET", "auth-token-secret", "HMAC secret for signing access tokens (random per process if empty)", secretValue{&c.Auth.TokenSecret}},
+		{"AUTH_TOKEN_TTL", "auth-token-ttl", "lifetime of issued access tokens", durationValue{&c.Auth.TokenTTL}},
+		{"DB_AUTO_MIGRATE", "db-auto-migrate", "apply pending schema migrations on startup", boolValue{&c.DB.AutoMigrate}},
+		{"DB_TX_RETRIES", "db-tx-retries", "times a deadlocked transaction is retried (0 = never)", intValue{&c.DB.TxRetries}},
+		{"LOG_FORMAT", "log-format", "log output format: json or text", stringValue{&c.Log.Format}},
+		{"LOG_LEVEL", "log-level", "minimum log level: debug, info, warn or error", stringValue{&c.Log.Level}},
+		{"LOG_SLOW_QUERY", "log-slow-query", "log SQL statements slower than this (0 = off)", durationValue{&c.Log.SlowQuery}},
+		{"TRACE_EXPORTER", "trace-exporter", "where to export trace spans: none, stdout or file", stringValue{&c.Trace.Exporter}},
+		{"TRACE_FILE", "trace-file", "file the file trace exporter appends spans to", stringValue{&c.Trace.File}},
+		{"RATE_LIMIT", "rate-limit", `requests each client may make per route, e.g. "300/m" (0 = unlimited)`, rateValue{&c.RateLimit.Default}},
+		{"RATE_LIMIT_ROUTES", "rate-limit-routes", `per-route limits, e.g. "POST /tasks=60/m,POST /users=10/h"`, rateMapValue{&c.RateLimit.Routes}},
+		{"RATE_LIMIT_PER_IP", "rate-limit-per-ip", `requests each remote IP may make per route before authentication, e.g. "1200/m" (0 = unlimited)`, rateValue{&c.RateLimit.PerIP}},
+		{"TRASH_RETENTION", "trash-retention", "how long deleted tasks and users can be restored before they are purged (0 = forever)", durationValue{&c.Trash.Retention}},
+	}
+}
+
+// Load builds the configuration from, in increasing precedence: defaults, the
+// JSON file named by -config or TASKMANAGER_CONFIG, TASKMANAGER_* environment
+// variables and command-line flags.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	return LoadFlags(flag.NewFlagSet("taskmanager", flag.ContinueOnError), args, getenv)
+}
+
+// LoadFlags is Load with a caller-supplied FlagSet, so subcommands can
+// register their own flags next to the configuration ones.
+func LoadFlags(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	configPath := fs.String(flagConfig, getenv(envConfig), "path to a JSON config file")
+	raw := make(map[string]*rawFlag, len(settings))
+	for _, s := range settings {
+		f := &rawFlag{}
+		_, f.bool = s.value.(boolValue)
+		raw[s.flag] = f
+		fs.Var(f, s.flag, fmt.Sprintf("%s (env %s%s)", s.usage, envPrefix, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if v := getenv(envPrefix + s.env); v != "" {
+			if err := s.value.set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", envPrefix, s.env, err))
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.value.set(raw[f.Name].value); err != nil {
+					errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.HTTP.Addr == "" {
+		errs = append(errs, errors.New("http.addr is required"))
+	}
+
+	if c.HTTP.ReadTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.IdleTimeout < 0 {
+		errs = append(errs, errors.New("http server timeouts cannot be negative"))
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("http.shutdown_timeout must be positive"))
+	}
+	if c.HTTP.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("http.shutdown_delay cannot be negative"))
+	}
+	if c.HTTP.ReadyTimeout <= 0 {
+		errs = append(errs, errors.New("http.ready_timeout must be positive"))
+	}
+	if c.HTTP.RequestTimeout < 0 {
+		errs = append(errs, errors.New("http.request_timeout cannot be negative"))
+	} else if !c.HTTP.fitsWriteTimeout(c.HTTP.RequestTimeout) {
+		errs = append(errs, errors.New("http.request_timeout must be shorter than http.write_timeout"))
+	}
+	for pattern, d := range c.HTTP.RouteTimeouts {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("http.route_timeouts[%q] cannot be negative", pattern))
+		} else if !c.HTTP.fitsWriteTimeout(d) {
+			errs = append(errs, fmt.Errorf("http.route_timeouts[%q] must be shorter than http.write_timeout", pattern))
+		}
+	}
+	if c.HTTP.MaxBodyBytes < 0 {
+		errs = append(errs, errors.New("http.max_body_bytes cannot be negative"))
+	}
+	if c.HTTP.IdempotencyTTL < 0 {
+		errs = append(errs, errors.New("http.idempotency_ttl cannot be negative"))
+	}
+
+	if !validStorage(c.Storage) {
+		errs = append(errs, fmt.Errorf("storage %q must be one of %v", c.Storage, storageBackends))
+	}
+	if c.Storage != "memory" && c.DB.DSN == "" {
+		errs = append(errs, fmt.Errorf("database DSN is required for the %s backend (set db.dsn)", c.Storage))
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("db.max_open_conns cannot be negative"))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db.max_idle_conns cannot be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("db.max_idle_conns cannot exceed db.max_open_conns"))
+	}
+	if c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("db connection lifetimes cannot be negative"))
+	}
+	if c.DB.TxRetries < 0 {
+		errs = append(errs, errors.New("db.tx_retries cannot be negative"))
+	}
+
+	if c.Auth.TokenSecret != "" && len(c.Auth.TokenSecret) < minSecretLength {
+		errs = append(errs, fmt.Errorf("auth.token_secret must be at least %d bytes", minSecretLength))
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.token_ttl must be positive"))
+	}
+
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format %q must be json or text", c.Log.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if c.Log.SlowQuery < 0 {
+		errs = append(errs, errors.New("log.slow_query cannot be negative"))
+	}
+
+	if !slices.Contains(traceExporters, c.Trace.Exporter) {
+		errs = append(errs, fmt.Errorf("trace.exporter %q must be one of %v", c.Trace.Exporter, traceExporters))
+	}
+	if c.Trace.Exporter == "file" && c.Trace.File == "" {
+		errs = append(errs, errors.New("trace.file is required for the file exporter"))
+	}
+
+	if c.Trash.Retention < 0 {
+		errs = append(errs, errors.New("trash.retention cannot be negative"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validStorage(name string) bool {
+	for _, backend := range storageBackends {
+		if backend == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the configuration as JSON with secrets redacted.
+func (c Config) String() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(data)
+}
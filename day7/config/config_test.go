@@ -0,0 +1,218 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDSN satisfies validation for tests that keep the default SQL storage.
+const testDSN = "app:secret@tcp(db:3306)/taskmanager"
+
+func envFrom(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := `{"http":{"addr":":7000"},"db":{"max_open_conns":10,"max_idle_conns":5,"conn_max_lifetime":"1m"}}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := envFrom(map[string]string{
+		"TASKMANAGER_CONFIG":            path,
+		"TASKMANAGER_HTTP_ADDR":         ":7001",
+		"TASKMANAGER_DB_MAX_IDLE_CONNS": "8",
+		"TASKMANAGER_DB_DSN":            testDSN,
+	})
+
+	cfg, err := Load([]string{"-addr", ":7002"}, env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.HTTP.Addr != ":7002" {
+		t.Errorf("Expected flag to win, got %s", cfg.HTTP.Addr)
+	}
+	if cfg.DB.MaxIdleConns != 8 {
+		t.Errorf("Expected env to override file, got %d", cfg.DB.MaxIdleConns)
+	}
+	if cfg.DB.MaxOpenConns != 10 || cfg.DB.ConnMaxLifetime.Std() != time.Minute {
+		t.Errorf("Expected file values, got %+v", cfg.DB)
+	}
+	if cfg.Storage != "mysql" {
+		t.Errorf("Expected default storage, got %s", cfg.Storage)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	_, err := Load([]string{"-storage", "oracle", "-db-max-open-conns", "2", "-db-max-idle-conns", "4"}, envFrom(nil))
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, want := range []string{"storage", "max_idle_conns"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	_, err = Load(nil, envFrom(map[string]string{"TASKMANAGER_DB_MAX_OPEN_CONNS": "many"}))
+	if err == nil || !strings.Contains(err.Error(), "TASKMANAGER_DB_MAX_OPEN_CONNS") {
+		t.Errorf("Expected env parse error, got %v", err)
+	}
+
+	_, err = Load(nil, envFrom(nil))
+	if err == nil || !strings.Contains(err.Error(), "database DSN is required") {
+		t.Errorf("Expected a missing DSN to be rejected, got %v", err)
+	}
+	if _, err := Load([]string{"-storage", "memory"}, envFrom(nil)); err != nil {
+		t.Errorf("Expected the memory backend to need no DSN, got %v", err)
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	cfg, err := Load([]string{"-dsn", "root:hunter2@tcp(db:3306)/taskmanager"}, envFrom(nil))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if strings.Contains(cfg.String(), "hunter2") {
+		t.Errorf("Config string leaks the DSN: %s", cfg)
+	}
+	if cfg.DB.DSN.Reveal() != "root:hunter2@tcp(db:3306)/taskmanager" {
+		t.Errorf("Unexpected DSN %q", cfg.DB.DSN.Reveal())
+	}
+}
+
+func TestRouteTimeouts(t *testing.T) {
+	env := envFrom(map[string]string{
+		"TASKMANAGER_HTTP_ROUTE_TIMEOUTS": "GET /users/{id}/tasks=5s, POST /tasks=750ms",
+		"TASKMANAGER_DB_DSN":              testDSN,
+	})
+
+	cfg, err := Load([]string{"-request-timeout", "2s"}, env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if got := cfg.HTTP.TimeoutFor("POST /tasks"); got != 750*time.Millisecond {
+		t.Errorf("Expected 750ms for POST /tasks, got %v", got)
+	}
+	if got := cfg.HTTP.TimeoutFor("GET /users/{id}/tasks"); got != 5*time.Second {
+		t.Errorf("Expected 5s for the listing, got %v", got)
+	}
+	if got := cfg.HTTP.TimeoutFor("GET /tasks/{id}"); got != 2*time.Second {
+		t.Errorf("Expected the 2s default, got %v", got)
+	}
+}
+
+func TestServerSettings(t *testing.T) {
+	env := envFrom(map[string]string{
+		"TASKMANAGER_HTTP_CORS_ORIGINS": "https://app.example.com, ,https://admin.example.com",
+		"TASKMANAGER_DB_DSN":            testDSN,
+	})
+
+	cfg, err := Load([]string{"-write-timeout", "20s", "-request-timeout", "15s"}, env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.HTTP.CORSOrigins) != 2 || cfg.HTTP.CORSOrigins[1] != "https://admin.example.com" {
+		t.Errorf("Unexpected CORS origins %q", cfg.HTTP.CORSOrigins)
+	}
+	if cfg.HTTP.ReadTimeout.Std() != 5*time.Second || cfg.HTTP.IdleTimeout.Std() != 120*time.Second {
+		t.Errorf("Expected default server timeouts, got %+v", cfg.HTTP)
+	}
+	if cfg.HTTP.IdempotencyTTL.Std() != 24*time.Hour {
+		t.Errorf("Expected idempotency keys to be kept for a day, got %v", cfg.HTTP.IdempotencyTTL)
+	}
+	if cfg.Trash.Retention.Std() != 30*24*time.Hour {
+		t.Errorf("Expected the trash to be kept for 30 days, got %v", cfg.Trash.Retention)
+	}
+	if _, err := Load([]string{"-trash-retention", "-1h"}, envFrom(nil)); err == nil || !strings.Contains(err.Error(), "trash.retention") {
+		t.Errorf("Expected a negative retention to be rejected, got %v", err)
+	}
+
+	_, err = Load([]string{"-route-timeouts", "POST /tasks=30s"}, envFrom(nil))
+	if err == nil || !strings.Contains(err.Error(), "write_timeout") {
+		t.Errorf("Expected a route timeout past the write timeout to be rejected, got %v", err)
+	}
+}
+
+func TestObservabilitySettings(t *testing.T) {
+	cfg, err := Load([]string{"-log-format", "text", "-trace-exporter", "file", "-trace-file", "/tmp/spans.jsonl"},
+		envFrom(map[string]string{"TASKMANAGER_LOG_SLOW_QUERY": "50ms", "TASKMANAGER_DB_DSN": testDSN}))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Log.Format != "text" || cfg.Log.Level != "info" || cfg.Log.SlowQuery.Std() != 50*time.Millisecond {
+		t.Errorf("Unexpected log config %+v", cfg.Log)
+	}
+	if cfg.Trace.Exporter != "file" || cfg.Trace.File != "/tmp/spans.jsonl" {
+		t.Errorf("Unexpected trace config %+v", cfg.Trace)
+	}
+
+	_, err = Load([]string{"-log-level", "loud", "-trace-exporter", "file"}, envFrom(nil))
+	if err == nil || !strings.Contains(err.Error(), "log.level") || !strings.Contains(err.Error(), "trace.file") {
+		t.Errorf("Expected the log level and trace file to be rejected, got %v", err)
+	}
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	cfg, err := Load([]string{"-rate-limit", "100/m"},
+		envFrom(map[string]string{
+			"TASKMANAGER_RATE_LIMIT_ROUTES": "POST /tasks=5/10s, GET /tasks/{id}=0",
+			"TASKMANAGER_DB_DSN":            testDSN,
+		}))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := cfg.RateLimit.RateFor("POST /tasks"); got.Requests != 5 || got.Per.Std() != 10*time.Second {
+		t.Errorf("Expected 5/10s for POST /tasks, got %v", got)
+	}
+	if got := cfg.RateLimit.RateFor("GET /tasks/{id}"); got.Requests != 0 {
+		t.Errorf("Expected GET /tasks/{id} to be unlimited, got %v", got)
+	}
+	if got := cfg.RateLimit.PerIP; got.Requests != 1200 || got.Per.Std() != time.Minute {
+		t.Errorf("Expected the default per-IP limit, got %+v", got)
+	}
+	if got := cfg.RateLimit.RateFor("DELETE /tasks/{id}"); got.Requests != 100 || got.Per.Std() != time.Minute {
+		t.Errorf("Expected the 100/m default, got %v", got)
+	}
+
+	_, err = Load([]string{"-rate-limit", "ten/m"}, envFrom(nil))
+	if err == nil || !strings.Contains(err.Error(), "rate-limit") {
+		t.Errorf("Expected a malformed rate to be rejected, got %v", err)
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	tests := map[string]string{
+		testDSN: testDSN + "?parseTime=true",
+		"app:secret@tcp(db:3306)/taskmanager?parseTime=false&loc=Local&timeout=5s": testDSN + "?parseTime=true&timeout=5s",
+	}
+	for dsn, want := range tests {
+		if got, err := MySQLDSN(dsn); err != nil || got != want {
+			t.Errorf("MySQLDSN(%q) = %q, %v, want %q", dsn, got, err, want)
+		}
+	}
+	if _, err := MySQLDSN("not a dsn"); err == nil {
+		t.Error("Expected a malformed DSN to be rejected")
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := map[string]string{
+		"app.db":                           "app.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+		"file:app.db?mode=rwc":             "file:app.db?mode=rwc&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+		"app.db?_pragma=busy_timeout(100)": "app.db?_pragma=busy_timeout(100)&_pragma=foreign_keys(1)",
+		"app.db?_pragma=foreign_keys(0)":   "app.db?_pragma=foreign_keys(0)&_pragma=busy_timeout(5000)",
+	}
+	for dsn, want := range tests {
+		if got := SQLiteDSN(dsn); got != want {
+			t.Errorf("SQLiteDSN(%q) = %q, want %q", dsn, got, want)
+		}
+	}
+}
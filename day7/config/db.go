@@ -3,28 +3,69 @@ package config
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
-var DB *sql.DB
+// ConnectDB opens and pings a connection pool sized from cfg.
+func ConnectDB(driver string, cfg DBConfig) (*sql.DB, error) {
+	dsn := cfg.DSN.Reveal()
+	switch driver {
+	case "mysql":
+		var err error
+		if dsn, err = MySQLDSN(dsn); err != nil {
+			return nil, fmt.Errorf("invalid %s DSN: %w", driver, err)
+		}
+	case "sqlite":
+		dsn = SQLiteDSN(dsn)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the %s database: %w", driver, err)
+	}
 
-func ConnectDB() {
-	var err error
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Std())
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime.Std())
 
-	dsn := "root:rootpassword@tcp(127.0.0.1:3306)/taskmanager"
-	DB, err = sql.Open("mysql", dsn)
-	if err != nil {
-		log.Fatalf("Unable to connect to the Database and got error :%v", err)
-		return
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to connect to the %s database: %w", driver, err)
 	}
+	return db, nil
+}
 
-	err = DB.Ping()
+// MySQLDSN makes the driver scan DATETIME columns into time.Time, which
+// every repository relies on, and read and write them as UTC.
+func MySQLDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
 	if err != nil {
-		log.Fatalf("Unable to connect to the Database and got error :%v", err)
-		return
+		return "", err
 	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
 
-	fmt.Println("Connected to MySQL successfully!")
+// SQLiteDSN turns on foreign keys, which the schema relies on and SQLite
+// leaves off, and waits for locks rather than failing, unless dsn already
+// sets those pragmas. Both apply to every connection in the pool.
+func SQLiteDSN(dsn string) string {
+	for _, pragma := range []string{"foreign_keys(1)", "busy_timeout(5000)"} {
+		name, _, _ := strings.Cut(pragma, "(")
+		if strings.Contains(dsn, "_pragma="+name) {
+			continue
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_pragma=" + pragma
+	}
+	return dsn
 }
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Secret holds a credential that must never reach logs or output.
+type Secret string
+
+const redacted = "******"
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// Duration is a time.Duration written as "30s" or "5m" in config files.
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	return d.set(s)
+}
+
+func (d *Duration) set(s string) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Rate is a request budget written as "60/m": Requests per Per, where Per
+// is s, m, h or a duration such as 10s. The zero Rate is unlimited.
+type Rate struct {
+	Requests int
+	Per      Duration
+}
+
+func (r Rate) String() string {
+	if r.Requests == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", r.Requests, r.Per)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("rate must be a string like \"60/m\": %w", err)
+	}
+	return r.set(s)
+}
+
+func (r *Rate) set(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "0" || s == "" {
+		*r = Rate{}
+		return nil
+	}
+
+	n, per, ok := strings.Cut(s, "/")
+	requests, err := strconv.Atoi(n)
+	if !ok || err != nil || requests <= 0 {
+		return fmt.Errorf("%q is not a rate like 60/m", s)
+	}
+	switch per {
+	case "s", "m", "h":
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("%q is not a rate like 60/m", s)
+	}
+	*r = Rate{Requests: requests, Per: Duration(d)}
+	return nil
+}
+
+// value parses a raw environment or flag string into a Config field.
+type value interface {
+	set(raw string) error
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) set(raw string) error {
+	*v.p = raw
+	return nil
+}
+
+type secretValue struct{ p *Secret }
+
+func (v secretValue) set(raw string) error {
+	*v.p = Secret(raw)
+	return nil
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) set(raw string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", raw)
+	}
+	*v.p = n
+	return nil
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) set(raw string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", raw)
+	}
+	*v.p = b
+	return nil
+}
+
+type durationValue struct{ p *Duration }
+
+func (v durationValue) set(raw string) error {
+	if err := v.p.set(raw); err != nil {
+		return fmt.Errorf("%q is not a duration", raw)
+	}
+	return nil
+}
+
+// durationMapValue parses "key=duration" pairs separated by commas.
+type durationMapValue struct{ p *map[string]Duration }
+
+func (v durationMapValue) set(raw string) error {
+	m := make(map[string]Duration)
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, d, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not a key=duration pair", pair)
+		}
+		var parsed Duration
+		if err := parsed.set(d); err != nil {
+			return fmt.Errorf("%q is not a duration", d)
+		}
+		m[strings.TrimSpace(key)] = parsed
+	}
+	*v.p = m
+	return nil
+}
+
+type rateValue struct{ p *Rate }
+
+func (v rateValue) set(raw string) error {
+	return v.p.set(raw)
+}
+
+// rateMapValue parses "key=rate" pairs separated by commas.
+type rateMapValue struct{ p *map[string]Rate }
+
+func (v rateMapValue) set(raw string) error {
+	m := make(map[string]Rate)
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, r, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not a key=rate pair", pair)
+		}
+		var parsed Rate
+		if err := parsed.set(r); err != nil {
+			return err
+		}
+		m[strings.TrimSpace(key)] = parsed
+	}
+	*v.p = m
+	return nil
+}
+
+// stringListValue parses a comma-separated list, dropping empty entries.
+type stringListValue struct{ p *[]string }
+
+func (v stringListValue) set(raw string) error {
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v.p = list
+	return nil
+}
+
+// rawFlag records what was passed on the command line so it can be applied
+// after the file and environment layers.
+type rawFlag struct {
+	value string
+	bool  bool
+}
+
+func (f *rawFlag) String() string { return f.value }
+
+func (f *rawFlag) Set(s string) error {
+	f.value = s
+	return nil
+}
+
+func (f *rawFlag) IsBoolFlag() bool { return f.bool }
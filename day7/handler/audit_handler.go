@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"taskmanager/models"
+	"taskmanager/service"
+	"time"
+)
+
+type AuditHandler struct {
+	errorWriter
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{
+		errorWriter:  errorWriter{logger: logger},
+		auditService: auditService,
+	}
+}
+
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	q, fields := parseAuditQuery(r)
+	if len(fields) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, service.ErrValidation.Code, service.ErrValidation.Message, fields)
+		return
+	}
+
+	page, err := h.auditService.ListAuditEvents(r.Context(), q)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set("Link", nextLink(r, page.NextCursor))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseAuditQuery reads the filters of GET /audit:
+//
+//	actor_id, action, entity_type, entity_id, request_id, since, until, limit, cursor
+func parseAuditQuery(r *http.Request) (*models.AuditQuery, []service.FieldError) {
+	values := r.URL.Query()
+	q := &models.AuditQuery{
+		Action:     models.AuditAction(values.Get("action")),
+		EntityType: values.Get("entity_type"),
+		RequestID:  strings.TrimSpace(values.Get("request_id")),
+		Cursor:     values.Get("cursor"),
+	}
+	var fields []service.FieldError
+
+	for _, p := range []struct {
+		name     string
+		dest     *int
+		positive bool
+	}{{"actor_id", &q.ActorID, true}, {"entity_id", &q.EntityID, true}, {"limit", &q.Limit, false}} {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		switch {
+		case err != nil:
+			fields = append(fields, service.FieldError{Field: p.name, Message: "must be an integer"})
+		case p.positive && n <= 0:
+			fields = append(fields, service.FieldError{Field: p.name, Message: "must be a positive id"})
+		}
+		*p.dest = n
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			fields = append(fields, service.FieldError{Field: p.name, Message: "must be an RFC 3339 timestamp or YYYY-MM-DD date"})
+			continue
+		}
+		*p.dest = &t
+	}
+
+	return q, fields
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taskmanager/auth"
+	"taskmanager/middleware"
+	"taskmanager/models"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	mux := newTestMux()
+	owner := tokenFor(1, auth.RoleUser)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"title":"Draft"}`))
+	req.Header.Set("Authorization", "Bearer "+owner)
+	req.Header.Set(middleware.RequestIDHeader, "create-draft")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	serveAs(mux, owner, http.MethodPatch, "/tasks/1", `{"title":"Final"}`)
+	serveAs(mux, owner, http.MethodDelete, "/tasks/1", "")
+	serveAs(mux, owner, http.MethodPatch, "/tasks/1", `{"title":"Gone"}`)
+
+	var page models.AuditPage
+	rec = serve(mux, http.MethodGet, "/audit?entity_type=task&entity_id=1", "")
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || len(page.Events) != 3 {
+		t.Fatalf("Expected 3 events for the task, got %d: %+v", rec.Code, page)
+	}
+	for i, want := range []models.AuditAction{models.AuditDelete, models.AuditUpdate, models.AuditCreate} {
+		if e := page.Events[i]; e.Action != want || e.ActorID != 1 || e.RequestID == "" {
+			t.Errorf("Event %d: expected %s by user 1 with a request id, got %+v", i, want, e)
+		}
+	}
+	created, updated, deleted := page.Events[2], page.Events[1], page.Events[0]
+	if created.RequestID != "create-draft" || string(created.Changes["title"].After) != `"Draft"` || created.Changes["title"].Before != nil {
+		t.Errorf("Unexpected create event: %+v", created)
+	}
+	if c := updated.Changes["title"]; string(c.Before) != `"Draft"` || string(c.After) != `"Final"` {
+		t.Errorf("Expected the title change, got %+v", updated.Changes)
+	}
+	if _, ok := updated.Changes["description"]; ok {
+		t.Errorf("Expected unchanged fields to be left out, got %+v", updated.Changes)
+	}
+	if c, ok := deleted.Changes["deleted_at"]; !ok || c.Before != nil || c.After == nil {
+		t.Errorf("Expected deleted_at to be set, got %+v", deleted.Changes)
+	}
+
+	rec = serveAs(mux, owner, http.MethodPut, "/users/1/password", `{"new_password":"a-new-password"}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	page = models.AuditPage{}
+	json.NewDecoder(serve(mux, http.MethodGet, "/audit?entity_type=user&action=update", "").Body).Decode(&page)
+	if len(page.Events) != 1 {
+		t.Fatalf("Expected the password change, got %+v", page)
+	}
+	if c, ok := page.Events[0].Changes["password"]; !ok || c.Before != nil || c.After != nil {
+		t.Errorf("Expected the password to be recorded without values, got %+v", page.Events[0].Changes)
+	}
+
+	rec = serve(mux, http.MethodGet, "/audit?limit=2", "")
+	page = models.AuditPage{}
+	if json.NewDecoder(rec.Body).Decode(&page); len(page.Events) != 2 || page.NextCursor == "" || !strings.Contains(rec.Header().Get("Link"), "cursor=") {
+		t.Fatalf("Expected a first page with a next link, got %+v", page)
+	}
+	last, cursor := page.Events[1].ID, page.NextCursor
+	page = models.AuditPage{}
+	json.NewDecoder(serve(mux, http.MethodGet, "/audit?limit=2&cursor="+cursor, "").Body).Decode(&page)
+	if len(page.Events) != 2 || page.Events[0].ID >= last {
+		t.Errorf("Expected the second page to continue after event %d, got %+v", last, page)
+	}
+
+	checks := []struct {
+		token  string
+		target string
+		want   int
+	}{
+		{tokenFor(2, auth.RoleUser), "/audit", http.StatusForbidden},
+		{"", "/audit?action=rename", http.StatusBadRequest},
+		{"", "/audit?entity_id=x", http.StatusBadRequest},
+		{"", "/audit?cursor=bogus", http.StatusBadRequest},
+		{"", "/audit?since=2026-02-01&until=2026-01-01", http.StatusBadRequest},
+	}
+	for _, c := range checks {
+		rec := serve(mux, http.MethodGet, c.target, "")
+		if c.token != "" {
+			rec = serveAs(mux, c.token, http.MethodGet, c.target, "")
+		}
+		if rec.Code != c.want {
+			t.Errorf("GET %s: expected %d, got %d", c.target, c.want, rec.Code)
+		}
+	}
+}
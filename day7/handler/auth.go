@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"taskmanager/auth"
+	"taskmanager/logging"
+	"taskmanager/service"
+)
+
+// RequireAuth rejects requests without a valid bearer token and stores the
+// caller's principal in the request context for the services to check. The
+// caller's user ID is added to the request's log attributes.
+func RequireAuth(authService service.AuthService, logger *slog.Logger, next http.Handler) http.Handler {
+	errs := errorWriter{logger: logger}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="taskmanager"`)
+			errs.writeError(w, r, service.ErrUnauthenticated)
+			return
+		}
+
+		p, err := authService.Authenticate(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="taskmanager", error="invalid_token"`)
+			errs.writeError(w, r, err)
+			return
+		}
+
+		logging.AddAttrs(r.Context(), slog.Int("user_id", p.UserID))
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// OptionalAuth is RequireAuth for public routes: anonymous requests pass
+// through, but a token that is present must be valid.
+func OptionalAuth(authService service.AuthService, logger *slog.Logger, next http.Handler) http.Handler {
+	required := RequireAuth(authService, logger, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		required.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"taskmanager/models"
+	"taskmanager/service"
+)
+
+type AuthHandler struct {
+	errorWriter
+	authService service.AuthService
+}
+
+func NewAuthHandler(authservice service.AuthService, logger *slog.Logger) *AuthHandler {
+	return &AuthHandler{
+		errorWriter: errorWriter{logger: logger},
+		authService: authservice,
+	}
+}
+
+type apiKeyResponse struct {
+	APIKey string `json:"api_key"`
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var creds models.Credentials
+	if !decodeBody(w, r, &creds, "Invalid login body") {
+		return
+	}
+
+	token, err := h.authService.Login(r.Context(), creds)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(token)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.authService.Logout(r.Context()); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "user")
+		return
+	}
+
+	key, err := h.authService.IssueAPIKey(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyResponse{APIKey: key})
+}
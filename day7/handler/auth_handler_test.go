@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"testing"
+)
+
+func serveAs(mux http.Handler, token, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func anonymous(mux http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header["Authorization"] = nil
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func login(t *testing.T, mux http.Handler, body string) string {
+	t.Helper()
+
+	rec := anonymous(mux, http.MethodPost, "/auth/login", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var token models.AccessToken
+	if err := json.NewDecoder(rec.Body).Decode(&token); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	return token.AccessToken
+}
+
+func TestRegisterLoginAndUseToken(t *testing.T) {
+	mux := newTestMux()
+
+	rec := anonymous(mux, http.MethodPost, "/users", `{"name":"Aman","email":" Aman@Example.com","password":"hunter2hunter2","role":"admin"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") || strings.Contains(rec.Body.String(), "argon2") {
+		t.Fatalf("Expected no password material in the response, got %s", rec.Body.String())
+	}
+
+	var created models.User
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if created.Role != auth.RoleUser || created.Email != "aman@example.com" {
+		t.Fatalf("Expected a plain user with a normalized email, got %+v", created)
+	}
+
+	token := login(t, mux, `{"email":"AMAN@example.com","password":"hunter2hunter2"}`)
+
+	rec = serveAs(mux, token, http.MethodPost, "/tasks", `{"title":"Mine"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var task models.Task
+	json.NewDecoder(rec.Body).Decode(&task)
+	if task.UserId != created.ID {
+		t.Errorf("Expected the task to belong to %d, got %d", created.ID, task.UserId)
+	}
+
+	if rec := serveAs(mux, token, http.MethodPost, "/auth/logout", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := serveAs(mux, token, http.MethodGet, "/tasks/"+strconv.Itoa(task.ID), ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the token to stop working after logout, got %d", rec.Code)
+	}
+}
+
+func TestRegistrationValidatesEmailAndPassword(t *testing.T) {
+	mux := newTestMux()
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"name":"A","email":"not-an-email","password":"longenough"}`, http.StatusBadRequest},
+		{`{"name":"A","email":"Name <a@example.com>","password":"longenough"}`, http.StatusBadRequest},
+		{`{"name":"A","email":"a@example.com","password":"short"}`, http.StatusBadRequest},
+		{`{"name":"A","email":"user1@example.com","password":"longenough"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		if rec := anonymous(mux, http.MethodPost, "/users", tt.body); rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.body, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestLoginWithAPIKey(t *testing.T) {
+	mux := newTestMux()
+
+	rec := serveAs(mux, tokenFor(1, auth.RoleUser), http.MethodPost, "/users/1/api-keys", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var key apiKeyResponse
+	json.NewDecoder(rec.Body).Decode(&key)
+
+	token := login(t, mux, `{"api_key":"`+key.APIKey+`"}`)
+	if rec := serveAs(mux, token, http.MethodGet, "/users/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestChangePasswordEndsSessions(t *testing.T) {
+	mux := newTestMux()
+
+	rec := anonymous(mux, http.MethodPost, "/users", `{"name":"Aman","email":"aman@example.com","password":"first-password"}`)
+	var user models.User
+	json.NewDecoder(rec.Body).Decode(&user)
+	path := "/users/" + strconv.Itoa(user.ID) + "/password"
+	token := login(t, mux, `{"email":"aman@example.com","password":"first-password"}`)
+
+	if rec := serveAs(mux, token, http.MethodPut, path, `{"current_password":"wrong-password","new_password":"second-password"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for a wrong current password, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := serveAs(mux, tokenFor(1, auth.RoleUser), http.MethodPut, path, `{"new_password":"second-password"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for another user, got %d", http.StatusForbidden, rec.Code)
+	}
+	if rec := serveAs(mux, token, http.MethodPut, path, `{"current_password":"first-password","new_password":"second-password"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+
+	if rec := serveAs(mux, token, http.MethodGet, "/users/"+strconv.Itoa(user.ID), ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the old session to end, got %d", rec.Code)
+	}
+	if rec := anonymous(mux, http.MethodPost, "/auth/login", `{"email":"aman@example.com","password":"first-password"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the old password to be rejected, got %d", rec.Code)
+	}
+	login(t, mux, `{"email":"aman@example.com","password":"second-password"}`)
+}
+
+func TestLoginRejectsUnknownKey(t *testing.T) {
+	mux := newTestMux()
+
+	rec := serve(mux, http.MethodPost, "/auth/login", `{"api_key":"tm_nope"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	rec = serve(mux, http.MethodPost, "/auth/login", `{"email":"nobody@example.com","password":"whatever-it-is"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d for an unknown email, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestRequestsNeedAValidToken(t *testing.T) {
+	mux := newTestMux()
+
+	rec := anonymous(mux, http.MethodGet, "/users/1/tasks", "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with a challenge, got %d", rec.Code)
+	}
+
+	if rec := serveAs(mux, "not-a-token", http.MethodGet, "/users/1/tasks", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d for a garbage token, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestUsersOnlyReachTheirOwnTasks(t *testing.T) {
+	mux := newTestMux()
+	owner := tokenFor(1, auth.RoleUser)
+	other := tokenFor(2, auth.RoleUser)
+
+	rec := serveAs(mux, owner, http.MethodPost, "/tasks", `{"title":"Private"}`)
+	var task models.Task
+	json.NewDecoder(rec.Body).Decode(&task)
+	path := "/tasks/" + strconv.Itoa(task.ID)
+
+	checks := []struct {
+		token, method, target, body string
+		want                        int
+	}{
+		{owner, http.MethodGet, path, "", http.StatusOK},
+		{other, http.MethodGet, path, "", http.StatusForbidden},
+		{other, http.MethodPatch, path, `{"title":"Mine now"}`, http.StatusForbidden},
+		{other, http.MethodDelete, path, "", http.StatusForbidden},
+		{other, http.MethodGet, "/users/1/tasks", "", http.StatusForbidden},
+		{other, http.MethodGet, "/users/1", "", http.StatusForbidden},
+		{other, http.MethodPost, "/tasks", `{"title":"Sneaky","user_id":1}`, http.StatusForbidden},
+		{owner, http.MethodPut, path, `{"title":"Give away","user_id":2}`, http.StatusForbidden},
+		{tokenFor(adminID, auth.RoleAdmin), http.MethodGet, path, "", http.StatusOK},
+	}
+
+	for _, c := range checks {
+		if rec := serveAs(mux, c.token, c.method, c.target, c.body); rec.Code != c.want {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.target, c.want, rec.Code)
+		}
+	}
+}
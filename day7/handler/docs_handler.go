@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+	"taskmanager/openapi"
+)
+
+type DocsHandler struct {
+	doc *openapi.Document
+}
+
+func NewDocsHandler(doc *openapi.Document) *DocsHandler {
+	return &DocsHandler{doc: doc}
+}
+
+// Spec serves the OpenAPI document.
+func (h *DocsHandler) Spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.doc.JSON())
+}
+
+// Docs serves a page that renders the document in the browser. It loads
+// nothing but /openapi.json, so it works without internet access.
+func (h *DocsHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	w.Write(h.doc.DocsPage())
+}
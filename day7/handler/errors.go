@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"taskmanager/service"
+)
+
+// problem is an RFC 7807 problem details body. Code is a stable identifier
+// clients can switch on; Errors lists field-level validation failures.
+type problem struct {
+	Type     string               `json:"type"`
+	Title    string               `json:"title"`
+	Status   int                  `json:"status"`
+	Detail   string               `json:"detail,omitempty"`
+	Instance string               `json:"instance,omitempty"`
+	Code     string               `json:"code"`
+	Errors   []service.FieldError `json:"errors,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fields []service.FieldError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+		Errors:   fields,
+	})
+}
+
+func badRequest(w http.ResponseWriter, r *http.Request, code, detail string) {
+	writeProblem(w, r, http.StatusBadRequest, code, detail, nil)
+}
+
+func invalidID(w http.ResponseWriter, r *http.Request, name string) {
+	writeProblem(w, r, http.StatusBadRequest, "invalid_id", name+" id must be an integer",
+		[]service.FieldError{{Field: "id", Message: "must be an integer"}})
+}
+
+// errorWriter is embedded by the handlers so unexpected errors are logged
+// with the request-scoped attributes of the logger they were built with.
+type errorWriter struct {
+	logger *slog.Logger
+}
+
+// writeError maps an error returned by a service to a problem response.
+// Anything that is not a typed service error is logged and reported as a
+// generic 500 so driver messages never reach the client.
+func (e errorWriter) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeProblem(w, r, http.StatusGatewayTimeout, "timeout", "request timed out", nil)
+		return
+	case errors.Is(err, context.Canceled):
+		// The client has gone away; nobody is left to read a response.
+		return
+	}
+
+	var svcErr *service.Error
+	if errors.As(err, &svcErr) && svcErr.Kind != service.KindInternal {
+		writeProblem(w, r, statusFor(svcErr.Kind), svcErr.Code, svcErr.Message, svcErr.Fields)
+		return
+	}
+
+	e.logger.ErrorContext(r.Context(), "request failed",
+		slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("error", err))
+	writeProblem(w, r, http.StatusInternalServerError, service.ErrInternal.Code, service.ErrInternal.Message, nil)
+}
+
+func statusFor(kind service.ErrorKind) int {
+	switch kind {
+	case service.KindValidation:
+		return http.StatusBadRequest
+	case service.KindNotFound:
+		return http.StatusNotFound
+	case service.KindConflict:
+		return http.StatusConflict
+	case service.KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case service.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case service.KindForbidden:
+		return http.StatusForbidden
+	case service.KindFailedDependency:
+		return http.StatusFailedDependency
+	case service.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) problem {
+	t.Helper()
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json, got %q", ct)
+	}
+	var p problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	return p
+}
+
+func TestValidationProblemListsFields(t *testing.T) {
+	mux := newTestMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"priority":"someday"}`)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	p := decodeProblem(t, rec)
+	if p.Code != "validation_failed" || p.Status != http.StatusBadRequest {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+
+	fields := map[string]bool{}
+	for _, f := range p.Errors {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{"title", "priority"} {
+		if !fields[want] {
+			t.Errorf("Expected a field error for %s, got %+v", want, p.Errors)
+		}
+	}
+}
+
+func TestNotFoundProblem(t *testing.T) {
+	mux := newTestMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/9", http.NoBody))
+
+	p := decodeProblem(t, rec)
+	if p.Code != "task_not_found" || p.Instance != "/tasks/9" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+}
+
+func TestInternalErrorsAreHidden(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/1", http.NoBody)
+
+	var logs bytes.Buffer
+	errs := errorWriter{logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	errs.writeError(rec, req, errors.New("Error 1045: Access denied for user 'root'@'localhost'"))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "1045") {
+		t.Errorf("Driver error leaked to client: %s", rec.Body.String())
+	}
+	if p := decodeProblem(t, rec); p.Code != "internal" {
+		t.Errorf("Expected internal code, got %q", p.Code)
+	}
+	if !strings.Contains(logs.String(), "level=ERROR") || !strings.Contains(logs.String(), "1045") {
+		t.Errorf("Expected the driver error to be logged, got %q", logs.String())
+	}
+}
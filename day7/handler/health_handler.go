@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"taskmanager/buildinfo"
+	"time"
+)
+
+// ReadinessCheck is one dependency /readyz verifies, such as the database.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	checks       []ReadinessCheck
+	timeout      time.Duration
+	logger       *slog.Logger
+	shuttingDown atomic.Bool
+}
+
+// NewHealthHandler runs checks on every /readyz request, each bounded by
+// timeout. Failures are logged rather than returned, since /readyz is
+// unauthenticated.
+func NewHealthHandler(timeout time.Duration, logger *slog.Logger, checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{
+		checks:  checks,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// ShuttingDown makes /readyz fail from now on so load balancers stop
+// sending traffic while in-flight requests drain.
+func (h *HealthHandler) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Healthz reports that the process is up and serving HTTP. It deliberately
+// checks nothing else, so a database outage does not get the process
+// restarted.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.shuttingDown.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "shutting_down"})
+		return
+	}
+
+	resp := healthResponse{Status: "ok", Checks: make(map[string]string, len(h.checks))}
+	status := http.StatusOK
+	for _, c := range h.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+		err := c.Check(ctx)
+		cancel()
+
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "readiness check failed", slog.String("check", c.Name), slog.Any("error", err))
+			resp.Checks[c.Name] = "unavailable"
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		} else {
+			resp.Checks[c.Name] = "ok"
+		}
+	}
+	writeHealth(w, status, resp)
+}
+
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildinfo.Get())
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"taskmanager/buildinfo"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	var dbErr error
+	h := NewHealthHandler(time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ReadinessCheck{Name: "database", Check: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("Expected the check to run with a deadline")
+			}
+			return dbErr
+		}})
+
+	readyz := func() (int, healthResponse) {
+		rec := httptest.NewRecorder()
+		h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var resp healthResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	if code, resp := readyz(); code != http.StatusOK || resp.Checks["database"] != "ok" {
+		t.Errorf("Expected ready, got %d %+v", code, resp)
+	}
+
+	dbErr = errors.New("dial tcp 10.0.0.5:3306: connection refused")
+	if code, resp := readyz(); code != http.StatusServiceUnavailable || resp.Checks["database"] != "unavailable" {
+		t.Errorf("Expected a failing check to report 503, got %d %+v", code, resp)
+	}
+
+	dbErr = nil
+	h.ShuttingDown()
+	if code, resp := readyz(); code != http.StatusServiceUnavailable || resp.Status != "shutting_down" {
+		t.Errorf("Expected readiness to fail during shutdown, got %d %+v", code, resp)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected liveness to stay ok during shutdown, got %d", rec.Code)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHealthHandler(time.Second, slog.Default()).Version(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	var info buildinfo.Info
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if info.Version != buildinfo.Version || info.GoVersion != runtime.Version() {
+		t.Errorf("Unexpected build info %+v", info)
+	}
+}
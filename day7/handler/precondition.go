@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"taskmanager/service"
+)
+
+// etag is the strong entity tag of a resource at version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// gzipETagSuffix marks the ETag of a gzip-compressed response, which
+// middleware.Gzip adds so that the two encodings do not share a strong
+// validator. Both forms name the same version.
+const gzipETagSuffix = "-gzip"
+
+// ifMatch reads the versions a write is conditional on from If-Match: a
+// list of ETags from earlier responses, or "*" for any version. When
+// required, a missing header gets 428 so clients cannot overwrite changes
+// they have not seen. Weak tags never match a write, so a list of only
+// weak tags gets 412. It writes the problem and returns false when the
+// request cannot go ahead.
+func ifMatch(w http.ResponseWriter, r *http.Request, required bool) ([]int, bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch raw {
+	case "":
+		if required {
+			writeProblem(w, r, http.StatusPreconditionRequired, "precondition_required",
+				"If-Match must carry the ETag of the version being changed", nil)
+			return nil, false
+		}
+		return []int{service.AnyVersion}, true
+	case "*":
+		return []int{service.AnyVersion}, true
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		value := strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`)
+		version, err := strconv.Atoi(strings.TrimSuffix(value, gzipETagSuffix))
+		if err != nil || version <= 0 || tag != `"`+value+`"` {
+			badRequest(w, r, "invalid_if_match", `If-Match must list ETags such as "3", or be "*"`)
+			return nil, false
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		writeProblem(w, r, http.StatusPreconditionFailed, service.ErrVersionMismatch.Code,
+			"If-Match only lists weak ETags, which never match a write", nil)
+		return nil, false
+	}
+	return versions, true
+}
+
+// eachVersion runs write with the versions If-Match listed in turn until
+// one is not a version mismatch. A mismatch is found before anything is
+// written, so trying the next version is safe.
+func eachVersion(versions []int, write func(version int) error) error {
+	var err error
+	for _, version := range versions {
+		if err = write(version); !errors.Is(err, service.ErrVersionMismatch) {
+			return err
+		}
+	}
+	return err
+}
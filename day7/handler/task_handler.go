@@ -2,6 +2,8 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"taskmanager/models"
@@ -9,52 +11,328 @@ import (
 )
 
 type TaskHandler struct {
+	errorWriter
 	taskService service.TaskService
 }
 
-func NewTaskHandler(taskservice service.TaskService) *TaskHandler {
+func NewTaskHandler(taskservice service.TaskService, logger *slog.Logger) *TaskHandler {
 	return &TaskHandler{
+		errorWriter: errorWriter{logger: logger},
 		taskService: taskservice,
 	}
 }
 
 func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	var task models.Task
-	err := json.NewDecoder(r.Body).Decode(&task)
+	if !decodeBody(w, r, &task, "Invalid Task Body") {
+		return
+	}
 
+	err := h.taskService.CreateTask(r.Context(), &task)
 	if err != nil {
-		http.Error(w, "Invalid Task Body", http.StatusBadRequest)
+		h.writeError(w, r, err)
 		return
 	}
 
-	err = h.taskService.CreateTask(&task)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
+	w.WriteHeader(http.StatusCreated)
+
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		invalidID(w, r, "task")
+		return
+	}
+
+	task, err := h.taskService.GetTask(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "task")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var task models.Task
+	if !decodeBody(w, r, &task, "Invalid Task Body") {
+		return
+	}
+	task.ID = id
 
+	err = eachVersion(versions, func(version int) error {
+		task.Version = version
+		return h.taskService.UpdateTask(r.Context(), &task)
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
 	json.NewEncoder(w).Encode(task)
 }
 
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "task")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var patch models.TaskPatch
+	if !decodeBody(w, r, &patch, "Invalid Task Body") {
+		return
+	}
+
+	var task *models.Task
+	err = eachVersion(versions, func(version int) (err error) {
+		task, err = h.taskService.PatchTask(r.Context(), id, version, &patch)
+		return err
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandler) TransitionTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "task")
+		return
+	}
+
+	// If-Match is optional here: a transition names the state it moves to
+	// rather than overwriting fields, and stale moves fail checkTransition.
+	versions, ok := ifMatch(w, r, false)
+	if !ok {
+		return
+	}
+
+	var transition models.TaskTransition
+	if !decodeBody(w, r, &transition, "Invalid transition body") {
+		return
+	}
+	if transition.Status == "" {
+		badRequest(w, r, "invalid_body", "Invalid transition body")
+		return
+	}
+
+	var task *models.Task
+	err = eachVersion(versions, func(version int) (err error) {
+		task, err = h.taskService.TransitionTask(r.Context(), id, version, transition.Status)
+		return err
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "task")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	err = eachVersion(versions, func(version int) error {
+		return h.taskService.DeleteTask(r.Context(), id, version)
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreTask takes If-Match optionally, like TransitionTask: a task only
+// comes out of the trash as it went in.
+func (h *TaskHandler) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "task")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, false)
+	if !ok {
+		return
+	}
+
+	var task *models.Task
+	err = eachVersion(versions, func(version int) (err error) {
+		task, err = h.taskService.RestoreTask(r.Context(), id, version)
+		return err
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
+	json.NewEncoder(w).Encode(task)
+}
+
+// GetTrash lists the caller's deleted tasks, or those of the user_id query
+// parameter.
+func (h *TaskHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	var userID int
+	if raw := r.URL.Query().Get("user_id"); raw != "" {
+		var err error
+		if userID, err = strconv.Atoi(raw); err != nil || userID <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, service.ErrValidation.Code, service.ErrValidation.Message,
+				[]service.FieldError{{Field: "user_id", Message: "must be a positive id"}})
+			return
+		}
+	}
+
+	trash, err := h.taskService.ListTrash(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trash)
+}
+
+type batchResponse struct {
+	Committed bool                `json:"committed"`
+	Results   []batchItemResponse `json:"results"`
+}
+
+// batchItemResponse carries the status the item would have had as a
+// single request, and either the task or the problem that stopped it.
+type batchItemResponse struct {
+	Index  int                `json:"index"`
+	Action models.BatchAction `json:"action"`
+	ID     int                `json:"id,omitempty"`
+	Status int                `json:"status"`
+	Task   *models.Task       `json:"task,omitempty"`
+	Error  *batchItemError    `json:"error,omitempty"`
+}
+
+type batchItemError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message"`
+	Errors  []service.FieldError `json:"errors,omitempty"`
+}
+
+// BatchTasks answers 200 when the batch committed, even if best-effort
+// items failed, and 422 when an atomic batch was rolled back.
+func (h *TaskHandler) BatchTasks(w http.ResponseWriter, r *http.Request) {
+	var batch models.TaskBatch
+	if !decodeBody(w, r, &batch, "Invalid batch body") {
+		return
+	}
+
+	result, err := h.taskService.BatchTasks(r.Context(), &batch)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	resp := batchResponse{Committed: result.Committed, Results: make([]batchItemResponse, len(result.Items))}
+	for i, item := range result.Items {
+		out := batchItemResponse{Index: i, Action: item.Action, ID: item.ID, Task: item.Task}
+		switch {
+		case item.Err != nil:
+			out.Status, out.Error = h.itemError(r, item.Err)
+		case item.Action == models.BatchCreate:
+			out.Status = http.StatusCreated
+			out.ID = item.Task.ID
+		case item.Action == models.BatchUpdate:
+			out.Status = http.StatusOK
+		default:
+			out.Status = http.StatusNoContent
+		}
+		resp.Results[i] = out
+	}
+
+	status := http.StatusOK
+	if !result.Committed {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// itemError is writeError for a single batch item.
+func (h *TaskHandler) itemError(r *http.Request, err error) (int, *batchItemError) {
+	var svcErr *service.Error
+	if errors.As(err, &svcErr) && svcErr.Kind != service.KindInternal {
+		return statusFor(svcErr.Kind), &batchItemError{Code: svcErr.Code, Message: svcErr.Message, Errors: svcErr.Fields}
+	}
+
+	h.logger.ErrorContext(r.Context(), "batch item failed", slog.Any("error", err))
+	return http.StatusInternalServerError, &batchItemError{Code: service.ErrInternal.Code, Message: service.ErrInternal.Message}
+}
+
 func (h *TaskHandler) GetUserTasks(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user Id", http.StatusBadRequest)
+		invalidID(w, r, "user")
 		return
 	}
 
-	var tasks []models.Task
-	tasks, err = h.taskService.GetTasksForUser(id)
+	q, fields := parseTaskQuery(r, id)
+	if len(fields) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, service.ErrValidation.Code, service.ErrValidation.Message, fields)
+		return
+	}
+
+	page, err := h.taskService.ListTasks(r.Context(), q)
 	if err != nil {
-		http.Error(w, "Invalid user Id", http.StatusBadRequest)
+		h.writeError(w, r, err)
 		return
 	}
 
+	if page.NextCursor != "" {
+		w.Header().Set("Link", nextLink(r, page.NextCursor))
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(page)
 }
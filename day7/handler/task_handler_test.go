@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"taskmanager/service"
+	"testing"
+)
+
+func TestTaskLifecycle(t *testing.T) {
+	mux := newTestMux()
+
+	body := bytes.NewBufferString(`{"title":"Write docs","user_id":7}`)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	body = bytes.NewBufferString(`{"description":"README and examples"}`)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/tasks/1", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var task models.Task
+	if err := json.NewDecoder(rec.Body).Decode(&task); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if task.Title != "Write docs" || task.Description != "README and examples" {
+		t.Errorf("Unexpected task after patch: %+v", task)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/7/tasks", http.NoBody))
+	var page models.TaskPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || len(page.Tasks) != 1 {
+		t.Errorf("Expected 1 task for user, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/tasks/1", http.NoBody))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestMissingTaskReturns404(t *testing.T) {
+	mux := newTestMux()
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/tasks/42", http.NoBody),
+		httptest.NewRequest(http.MethodPut, "/tasks/42", bytes.NewBufferString(`{"title":"x","user_id":1}`)),
+		httptest.NewRequest(http.MethodPatch, "/tasks/42", bytes.NewBufferString(`{"title":"x"}`)),
+		httptest.NewRequest(http.MethodDelete, "/tasks/42", http.NoBody),
+	}
+
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected %d, got %d", req.Method, req.URL.Path, http.StatusNotFound, rec.Code)
+		}
+	}
+}
+
+func TestTransitionTask(t *testing.T) {
+	mux := newTestMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"title":"Ship","user_id":1}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	steps := []struct {
+		status string
+		code   int
+	}{
+		{"in_progress", http.StatusOK},
+		{"done", http.StatusOK},
+		{"archived", http.StatusOK},
+		{"open", http.StatusConflict},
+		{"bogus", http.StatusBadRequest},
+	}
+
+	for _, step := range steps {
+		body := bytes.NewBufferString(`{"status":"` + step.status + `"}`)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks/1/transition", body))
+		if rec.Code != step.code {
+			t.Errorf("transition to %s: expected %d, got %d", step.status, step.code, rec.Code)
+		}
+	}
+	// Archived is terminal, so the rest of the task is frozen too.
+	for method, body := range map[string]string{
+		http.MethodPut:   `{"title":"Revived","user_id":1}`,
+		http.MethodPatch: `{"title":"Revived"}`,
+	} {
+		req := httptest.NewRequest(method, "/tasks/1", bytes.NewBufferString(body))
+		req.Header.Set("If-Match", `"4"`)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusConflict || decodeProblem(t, rec).Code != "invalid_transition" {
+			t.Errorf("%s of an archived task: expected %d, got %d", method, http.StatusConflict, rec.Code)
+		}
+	}
+}
+
+func TestListTasksPaginates(t *testing.T) {
+	mux := newTestMux()
+
+	for _, title := range []string{"alpha", "beta", "gamma", "delta", "other"} {
+		rec := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{"title":"` + title + `","user_id":3}`)
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", body))
+	}
+
+	var seen []string
+	next := "/users/3/tasks?limit=2&sort=-title&q=a"
+	for next != "" {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, next, http.NoBody))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+
+		var page models.TaskPage
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		for _, task := range page.Tasks {
+			seen = append(seen, task.Title)
+		}
+
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			next = strings.TrimPrefix(strings.Split(link, ">")[0], "<")
+		}
+	}
+
+	want := "gamma,delta,beta,alpha"
+	if got := strings.Join(seen, ","); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestListTasksRejectsBadParameters(t *testing.T) {
+	mux := newTestMux()
+
+	for _, query := range []string{"limit=x", "limit=1000", "sort=colour", "status=later", "cursor=bogus", "due_before=soon"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1/tasks?"+query, http.NoBody))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", query, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestBatchTasks(t *testing.T) {
+	mux := newTestMux()
+	for _, title := range []string{"Existing", "Doomed"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewBufferString(`{"title":"`+title+`","user_id":1}`)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+		}
+	}
+
+	items := `[
+		{"action":"create","task":{"title":"Imported","user_id":1}},
+		{"action":"create","task":{"title":"Orphan","user_id":999}},
+		{"action":"update","id":1,"version":1,"status":"done"},
+		{"action":"delete","id":2,"version":1}
+	]`
+	send := func(mode string) (int, batchResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{"mode":"` + mode + `","items":` + items + `}`)
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks:batch", body))
+		var resp batchResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		return rec.Code, resp
+	}
+	statuses := func(resp batchResponse) []int {
+		var out []int
+		for _, item := range resp.Results {
+			out = append(out, item.Status)
+		}
+		return out
+	}
+
+	code, resp := send("atomic")
+	if code != http.StatusUnprocessableEntity || resp.Committed {
+		t.Fatalf("Expected an aborted atomic batch, got %d %+v", code, resp)
+	}
+	if got := statuses(resp); !slices.Equal(got, []int{424, 422, 424, 424}) {
+		t.Errorf("Unexpected item statuses %v", got)
+	}
+	if resp.Results[1].Error == nil || resp.Results[1].Error.Code != "unknown_user" {
+		t.Errorf("Expected unknown_user for the orphan, got %+v", resp.Results[1])
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/2", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the atomic batch to leave task 2 alone, got %d", rec.Code)
+	}
+
+	code, resp = send("best_effort")
+	if code != http.StatusOK || !resp.Committed {
+		t.Fatalf("Expected a committed best-effort batch, got %d %+v", code, resp)
+	}
+	if got := statuses(resp); !slices.Equal(got, []int{201, 422, 200, 204}) {
+		t.Errorf("Unexpected item statuses %v", got)
+	}
+	if task := resp.Results[2].Task; task == nil || task.Status != models.StatusDone || task.CompletedAt == nil {
+		t.Errorf("Expected task 1 to be done, got %+v", task)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+strconv.Itoa(resp.Results[0].ID), http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the created task to exist, got %d", rec.Code)
+	}
+}
+
+func TestBatchTasksRejectsMalformedBatches(t *testing.T) {
+	mux := newTestMux()
+
+	tooMany := `{"items":[` + strings.Repeat(`{"action":"delete","id":1},`, service.MaxBatchSize) + `{"action":"delete","id":1}]}`
+	bodies := []string{
+		`{"items":[]}`,
+		`{"mode":"sometimes","items":[{"action":"delete","id":1}]}`,
+		`{"items":[{"action":"archive","id":1}]}`,
+		tooMany,
+	}
+	for _, body := range bodies {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks:batch", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%.60s: expected %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	mux := newTestMux()
+	serve(mux, http.MethodPost, "/tasks", `{"title":"Draft","user_id":1}`)
+
+	conditional := func(method, target, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header["If-Match"] = []string{ifMatch}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(mux, http.MethodGet, "/tasks/1", "")
+	if got := rec.Header().Get("ETag"); got != `"1"` {
+		t.Fatalf(`Expected ETag "1", got %q`, got)
+	}
+
+	if rec := conditional(http.MethodPatch, "/tasks/1", "", `{"title":"Blind"}`); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected %d without If-Match, got %d", http.StatusPreconditionRequired, rec.Code)
+	}
+	if rec := conditional(http.MethodPatch, "/tasks/1", "1", `{"title":"Unquoted"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for a malformed If-Match, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = conditional(http.MethodPatch, "/tasks/1", `"1"`, `{"title":"First"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected 200 with ETag \"2\", got %d with %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	rec = conditional(http.MethodPatch, "/tasks/1", `"1"`, `{"title":"Second"}`)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected %d for a stale If-Match, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Code != "version_mismatch" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+	if rec := conditional(http.MethodDelete, "/tasks/1", `"1"`, ""); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected a stale delete to fail with %d, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+
+	var task models.Task
+	json.NewDecoder(serve(mux, http.MethodGet, "/tasks/1", "").Body).Decode(&task)
+	if task.Title != "First" || task.Version != 2 {
+		t.Errorf("Expected the first write only, got %+v", task)
+	}
+
+	if rec := conditional(http.MethodPatch, "/tasks/1", `W/"2"`, `{"title":"Weak"}`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected a weak ETag to fail with %d, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+	rec = conditional(http.MethodPatch, "/tasks/1", `"1", W/"2", "2-gzip"`, `{"title":"Listed"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected a list to match its gzip ETag, got %d with %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := conditional(http.MethodPatch, "/tasks/1", `"1", "2"`, `{"title":"Stale"}`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected a list of stale ETags to fail with %d, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+
+	var resp batchResponse
+	rec = serve(mux, http.MethodPost, "/tasks:batch", `{"mode":"best_effort","items":[{"action":"update","id":1,"version":1,"status":"done"}]}`)
+	if json.NewDecoder(rec.Body).Decode(&resp); len(resp.Results) != 1 || resp.Results[0].Status != http.StatusPreconditionFailed {
+		t.Errorf("Expected a stale batch item to fail with %d, got %+v", http.StatusPreconditionFailed, resp)
+	}
+	rec = serve(mux, http.MethodPost, "/tasks:batch", `{"mode":"best_effort","items":[{"action":"delete","id":1}]}`)
+	if json.NewDecoder(rec.Body).Decode(&resp); len(resp.Results) != 1 || resp.Results[0].Status != http.StatusBadRequest {
+		t.Errorf("Expected a batch item without a version to fail with %d, got %+v", http.StatusBadRequest, resp)
+	}
+
+	if rec := conditional(http.MethodDelete, "/tasks/1", `"3"`, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	mux := newTestMux()
+	owner := tokenFor(1, auth.RoleUser)
+	serve(mux, http.MethodPost, "/tasks", `{"title":"Mine","user_id":1}`)
+	serve(mux, http.MethodPost, "/tasks", `{"title":"Theirs","user_id":2}`)
+
+	if rec := serveAs(mux, owner, http.MethodDelete, "/tasks/1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := serveAs(mux, owner, http.MethodGet, "/tasks/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a deleted task to be %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	var trash models.Trash
+	rec := serveAs(mux, owner, http.MethodGet, "/trash", "")
+	if json.NewDecoder(rec.Body).Decode(&trash); len(trash.Tasks) != 1 || trash.Tasks[0].DeletedAt == nil {
+		t.Fatalf("Expected the deleted task in the trash, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(mux, owner, http.MethodGet, "/trash?user_id=2", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for another user's trash, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	rec = serveAs(mux, owner, http.MethodPost, "/tasks/1/restore", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("Expected 200 with ETag \"3\", got %d with %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := serveAs(mux, owner, http.MethodGet, "/tasks/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the restored task, got %d", rec.Code)
+	}
+	if rec := serveAs(mux, owner, http.MethodPost, "/tasks/1/restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for a task not in the trash, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	if rec := serve(mux, http.MethodDelete, "/users/2?tasks=cascade", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	trash = models.Trash{}
+	json.NewDecoder(serve(mux, http.MethodGet, "/trash?user_id=2", "").Body).Decode(&trash)
+	if len(trash.Tasks) != 1 || len(trash.Users) != 1 || trash.Users[0].ID != 2 {
+		t.Errorf("Expected the deleted user and their task, got %+v", trash)
+	}
+	if rec := serve(mux, http.MethodPost, "/tasks/2/restore", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected %d while the owner is deleted, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if rec := serveAs(mux, owner, http.MethodPost, "/users/2/restore", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected %d for a non-admin, got %d", http.StatusForbidden, rec.Code)
+	}
+	if rec := serve(mux, http.MethodPost, "/users/2/restore", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if rec := serve(mux, http.MethodPost, "/tasks/2/restore", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the task to restore with its owner back, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"taskmanager/models"
+	"taskmanager/service"
+	"time"
+)
+
+// parseTaskQuery reads the listing parameters:
+//
+//	limit, cursor, status, priority, due_before, due_after, q, sort
+//
+// status and priority accept comma-separated or repeated values; sort takes
+// a field name prefixed with "-" for descending order.
+func parseTaskQuery(r *http.Request, userID int) (*models.TaskQuery, []service.FieldError) {
+	values := r.URL.Query()
+	q := &models.TaskQuery{
+		UserID: userID,
+		Search: strings.TrimSpace(values.Get("q")),
+		Cursor: values.Get("cursor"),
+	}
+	var fields []service.FieldError
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			fields = append(fields, service.FieldError{Field: "limit", Message: "must be an integer"})
+		}
+		q.Limit = n
+	}
+
+	for _, status := range listParam(values, "status") {
+		q.Statuses = append(q.Statuses, models.TaskStatus(status))
+	}
+	for _, priority := range listParam(values, "priority") {
+		q.Priorities = append(q.Priorities, models.TaskPriority(priority))
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{{"due_before", &q.DueBefore}, {"due_after", &q.DueAfter}} {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			fields = append(fields, service.FieldError{Field: p.name, Message: "must be an RFC 3339 timestamp or YYYY-MM-DD date"})
+			continue
+		}
+		*p.dest = &t
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		q.Sort.Field, q.Sort.Desc = strings.CutPrefix(sort, "-")
+	}
+
+	return q, fields
+}
+
+func listParam(values url.Values, name string) []string {
+	var out []string
+	for _, v := range values[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+func parseTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, raw)
+}
+
+// nextLink is the RFC 8288 Link header value for the following page.
+func nextLink(r *http.Request, cursor string) string {
+	values := r.URL.Query()
+	values.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+	return "<" + next.String() + `>; rel="next"`
+}
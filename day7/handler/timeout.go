@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithTimeout bounds the request context, and so every query made on its
+// behalf, to timeout. A zero timeout leaves the handler untouched.
+func WithTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
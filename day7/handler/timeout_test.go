@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeoutSetsDeadline(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	h := WithTimeout(time.Second, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if !ok || time.Until(deadline) > time.Second {
+		t.Errorf("Expected a deadline within a second, got %v (set: %v)", deadline, ok)
+	}
+}
+
+func TestTimedOutRequestReturns504(t *testing.T) {
+	mux := newTestMux()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/1", http.NoBody).WithContext(ctx)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected %d, got %d", http.StatusGatewayTimeout, rec.Code)
+	}
+}
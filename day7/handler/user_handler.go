@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"taskmanager/models"
@@ -9,30 +10,44 @@ import (
 )
 
 type UserHandler struct {
+	errorWriter
 	userService service.UserService
 }
 
-func NewUserHandler(userservice service.UserService) *UserHandler {
+func NewUserHandler(userservice service.UserService, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
+		errorWriter: errorWriter{logger: logger},
 		userService: userservice,
 	}
 }
 
-func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+type createUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
 
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if !decodeBody(w, r, &req, "Invalid request body") {
 		return
 	}
 
-	err = h.userService.CreateUser(&user)
+	user := models.User{Name: req.Name, Email: req.Email, Role: req.Role}
+	err := h.userService.CreateUser(r.Context(), &user, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.writeError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
 }
@@ -41,17 +56,108 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		invalidID(w, r, "user")
 		return
 	}
 
 	var user *models.User
-	user, err = h.userService.GetUser(id)
+	user, err = h.userService.GetUser(r.Context(), id)
+
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "user")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req changePasswordRequest
+	if !decodeBody(w, r, &req, "Invalid request body") {
+		return
+	}
+
+	err = eachVersion(versions, func(version int) error {
+		return h.userService.ChangePassword(r.Context(), id, version, req.CurrentPassword, req.NewPassword)
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "user")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	disposition := models.TaskDisposition(r.URL.Query().Get("tasks"))
+	var reassignTo int
+	if raw := r.URL.Query().Get("reassign_to"); raw != "" {
+		reassignTo, err = strconv.Atoi(raw)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, service.ErrValidation.Code, service.ErrValidation.Message,
+				[]service.FieldError{{Field: "reassign_to", Message: "must be an integer"}})
+			return
+		}
+	}
+
+	err = eachVersion(versions, func(version int) error {
+		return h.userService.DeleteUser(r.Context(), id, version, disposition, reassignTo)
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
 
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		invalidID(w, r, "user")
+		return
+	}
+
+	versions, ok := ifMatch(w, r, false)
+	if !ok {
+		return
+	}
+
+	var user *models.User
+	err = eachVersion(versions, func(version int) (err error) {
+		user, err = h.userService.RestoreUser(r.Context(), id, version)
+		return err
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		h.writeError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
 	json.NewEncoder(w).Encode(user)
 }
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taskmanager/models"
+	"testing"
+)
+
+func serve(mux http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestCreateTaskForUnknownUserReturns422(t *testing.T) {
+	mux := newTestMux()
+
+	rec := serve(mux, http.MethodPost, "/tasks", `{"title":"Orphan","user_id":404}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Code != "unknown_user" || len(p.Errors) != 1 || p.Errors[0].Field != "user_id" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+}
+
+func TestDeleteUserTaskDispositions(t *testing.T) {
+	mux := newTestMux()
+	serve(mux, http.MethodPost, "/tasks", `{"title":"One","user_id":1}`)
+	serve(mux, http.MethodPost, "/tasks", `{"title":"Two","user_id":1}`)
+	serve(mux, http.MethodPost, "/tasks", `{"title":"Three","user_id":2}`)
+
+	if rec := serve(mux, http.MethodDelete, "/users/1", ""); rec.Code != http.StatusConflict {
+		t.Errorf("reject: expected %d, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := serve(mux, http.MethodDelete, "/users/1?tasks=reassign&reassign_to=99", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reassign to missing user: expected %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if rec := serve(mux, http.MethodDelete, "/users/1?tasks=reassign&reassign_to=3", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("reassign: expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	var page models.TaskPage
+	json.NewDecoder(serve(mux, http.MethodGet, "/users/3/tasks", "").Body).Decode(&page)
+	if len(page.Tasks) != 2 {
+		t.Errorf("Expected user 3 to own 2 tasks, got %d", len(page.Tasks))
+	}
+
+	if rec := serve(mux, http.MethodDelete, "/users/2?tasks=cascade", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("cascade: expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := serve(mux, http.MethodGet, "/tasks/3", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected cascaded task to be gone, got %d", rec.Code)
+	}
+	if rec := serve(mux, http.MethodGet, "/users/2", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted user to be gone, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"taskmanager/openapi"
+	"taskmanager/service"
+)
+
+// ValidateBody checks the request body against the schema doc declares for
+// pattern before next runs, answering 400 with one field error per
+// violation. It panics if doc has no operation for pattern, so every route
+// registered through it is documented.
+func ValidateBody(doc *openapi.Document, pattern string, next http.Handler) http.Handler {
+	op, ok := doc.Operation(pattern)
+	if !ok {
+		panic("handler: " + pattern + " is not in the OpenAPI document")
+	}
+	if op.Body == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			bodyError(w, r, err, "could not read the request body")
+			return
+		}
+
+		violations, err := op.Body.Validate(data)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_body", "request body is not valid JSON",
+				[]service.FieldError{{Field: "", Message: "must be a single JSON value"}})
+			return
+		}
+		if len(violations) > 0 {
+			fields := make([]service.FieldError, len(violations))
+			for i, v := range violations {
+				fields[i] = service.FieldError{Field: v.Field, Message: v.Message}
+			}
+			writeProblem(w, r, http.StatusBadRequest, service.ErrValidation.Code, service.ErrValidation.Message, fields)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeBody decodes the JSON request body into v, rejecting unknown
+// fields and trailing data. On failure it writes the problem response,
+// using detail for malformed bodies, and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any, detail string) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&json.RawMessage{}) != io.EOF {
+		err = errors.New("trailing data after the JSON value")
+	}
+	if err != nil {
+		bodyError(w, r, err, detail)
+		return false
+	}
+	return true
+}
+
+// bodyError reports a body that could not be read or decoded. Bodies cut
+// off by LimitBody get 413 even when no Content-Length announced them.
+func bodyError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit), nil)
+		return
+	}
+	badRequest(w, r, "invalid_body", detail)
+}
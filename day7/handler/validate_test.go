@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taskmanager/openapi"
+	"testing"
+)
+
+func TestRequestBodiesAreValidated(t *testing.T) {
+	mux := newTestMux()
+
+	tests := []struct {
+		name, method, target, body string
+		code                       string
+		fields                     map[string]string
+	}{
+		{"unknown field", http.MethodPost, "/tasks", `{"title":"a","owner":3}`, "validation_failed",
+			map[string]string{"owner": "is not a known field"}},
+		{"wrong types", http.MethodPost, "/tasks", `{"title":7,"user_id":"7","due_date":"tomorrow"}`, "validation_failed",
+			map[string]string{"title": "must be a string", "user_id": "must be an integer", "due_date": "must be an RFC 3339 timestamp"}},
+		{"enum", http.MethodPost, "/tasks/1/transition", `{"status":"finished"}`, "validation_failed",
+			map[string]string{"status": "must be one of open, in_progress, blocked, done, archived"}},
+		{"missing required", http.MethodPost, "/users", `{"email":"a@example.com","password":"longenough"}`, "validation_failed",
+			map[string]string{"name": "is required"}},
+		{"null", http.MethodPut, "/tasks/1", `{"title":null}`, "validation_failed",
+			map[string]string{"title": "must not be null"}},
+		{"not an object", http.MethodPost, "/auth/login", `["a@example.com"]`, "validation_failed",
+			map[string]string{"": "must be an object"}},
+		{"malformed", http.MethodPost, "/tasks", `{"title":"a"`, "invalid_body",
+			map[string]string{"": "must be a single JSON value"}},
+		{"trailing data", http.MethodPost, "/tasks", `{"title":"a"} {"title":"b"}`, "invalid_body",
+			map[string]string{"": "must be a single JSON value"}},
+	}
+
+	for _, tt := range tests {
+		rec := serve(mux, tt.method, tt.target, tt.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %s", tt.name, rec.Code, rec.Body.String())
+			continue
+		}
+		p := decodeProblem(t, rec)
+		if p.Code != tt.code {
+			t.Errorf("%s: expected code %s, got %+v", tt.name, tt.code, p)
+		}
+		got := map[string]string{}
+		for _, f := range p.Errors {
+			got[f.Field] = f.Message
+		}
+		for field, message := range tt.fields {
+			if got[field] != message {
+				t.Errorf("%s: expected %q for %q, got %+v", tt.name, message, field, p.Errors)
+			}
+		}
+	}
+
+	// Nulls in a patch mean "leave unchanged", as before.
+	serve(mux, http.MethodPost, "/tasks", `{"title":"a","user_id":1,"due_date":"2030-01-02T15:04:05Z"}`)
+	if rec := serve(mux, http.MethodPatch, "/tasks/1", `{"title":"b","due_date":null,"priority":null}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected a patch with nulls to succeed, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOversizedBodyWithoutLengthIs413(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ValidateBody(doc, "POST /tasks", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("Expected the handler not to run")
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/tasks", io.NopCloser(strings.NewReader(`{"title":"`+strings.Repeat("a", 100)+`"}`)))
+	req.ContentLength = -1
+	req.Body = http.MaxBytesReader(rec, req.Body, 64)
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge || decodeProblem(t, rec).Code != "body_too_large" {
+		t.Errorf("Expected 413 body_too_large, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDocsAreServed(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewDocsHandler(doc)
+
+	rec := httptest.NewRecorder()
+	h.Spec(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Header().Get("Content-Type") != "application/json" || !strings.Contains(rec.Body.String(), `"openapi": "3.0.3"`) {
+		t.Errorf("Unexpected document response %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	h.Docs(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(rec.Body.String(), `fetch("openapi.json")`) {
+		t.Errorf("Unexpected docs page response %v", rec.Header())
+	}
+}
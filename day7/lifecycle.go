@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// runServer serves on ln until ctx is cancelled. It then calls draining,
+// keeps serving for delay so load balancers notice readiness failing, stops
+// accepting connections and gives in-flight requests up to grace to finish.
+// Requests still running after that are cut off and reported as an error.
+func runServer(ctx context.Context, server *http.Server, ln net.Listener, delay, grace time.Duration, draining func()) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	if draining != nil {
+		draining()
+	}
+	if delay > 0 {
+		slog.Info("shutting down, failing readiness before draining", slog.Duration("delay", delay))
+		time.Sleep(delay)
+	}
+
+	slog.Info("shutting down, draining in-flight requests", slog.Duration("grace", grace))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("requests still running after %s: %w", grace, err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// workers tracks background goroutines so shutdown can wait for them to
+// notice their context is done before the resources they use are closed.
+type workers struct {
+	wg sync.WaitGroup
+}
+
+func (w *workers) Go(ctx context.Context, name string, fn func(context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(ctx)
+		slog.Info("background worker stopped", slog.String("worker", name))
+	}()
+}
+
+func (w *workers) Wait(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("background workers still running after %s", timeout)
+	}
+}
+
+// every runs fn once per interval until ctx is done.
+func every(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"taskmanager/handler"
+	"testing"
+	"time"
+)
+
+// startServer runs handler under runServer and returns its address, a
+// cancel func that begins shutdown and a channel with runServer's result.
+func startServer(t *testing.T, handler http.Handler, delay, grace time.Duration, draining func()) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runServer(ctx, &http.Server{Handler: handler}, ln, delay, grace, draining)
+	}()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	addr, shutdown, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "finished")
+	}), 0, 5*time.Second, nil)
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(addr)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+
+	<-started
+	shutdown()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected shutdown to wait for the request, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-result; got != "finished" {
+		t.Errorf("Expected the in-flight request to complete, got %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if _, err := http.Get(addr); err == nil {
+		t.Error("Expected new connections to be refused after shutdown")
+	}
+}
+
+func TestShutdownGivesUpAfterGracePeriod(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	addr, shutdown, done := startServer(t, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	}), 0, 20*time.Millisecond, nil)
+
+	go http.Get(addr)
+	<-started
+	shutdown()
+
+	if err := <-done; err == nil {
+		t.Error("Expected an error when requests outlive the grace period")
+	}
+}
+
+func TestReadinessFailsBeforeDraining(t *testing.T) {
+	health := handler.NewHealthHandler(time.Second, slog.Default())
+	addr, shutdown, done := startServer(t, http.HandlerFunc(health.Readyz), 200*time.Millisecond, time.Second, health.ShuttingDown)
+
+	resp, err := http.Get(addr)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected ready before shutdown, got %v, %v", resp, err)
+	}
+	resp.Body.Close()
+
+	shutdown()
+	time.Sleep(50 * time.Millisecond)
+	resp, err = http.Get(addr)
+	if err != nil {
+		t.Fatalf("Expected the server to keep answering during the delay, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected %d while shutting down, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestWorkersWait(t *testing.T) {
+	var w workers
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Go(ctx, "test", func(ctx context.Context) { <-ctx.Done() })
+
+	if err := w.Wait(10 * time.Millisecond); err == nil {
+		t.Error("Expected Wait to time out while the worker runs")
+	}
+	cancel()
+	if err := w.Wait(time.Second); err != nil {
+		t.Errorf("Expected the worker to stop, got %v", err)
+	}
+}
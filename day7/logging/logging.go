@@ -0,0 +1,108 @@
+// Package logging builds the application's slog logger and carries
+// request-scoped attributes and the request id through contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// New returns a logger writing format ("json" or "text") to w, dropping
+// records below level. Records logged with a context carry the attributes
+// added to it with AddAttrs.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("log format %q must be json or text", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// Discard is a logger for tests and tools that do not want output.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// scope collects attributes for one request. It is shared by pointer so
+// attributes added deep in the stack, such as the authenticated user, are
+// also seen by middleware that logs after the handler returns.
+type scope struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type scopeKey struct{}
+
+type requestIDKey struct{}
+
+// NewContext starts a fresh attribute scope, normally once per request.
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{attrs: attrs})
+}
+
+// AddAttrs adds attributes to the scope in ctx. Without a scope it does
+// nothing.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mu.Lock()
+		s.attrs = append(s.attrs, attrs...)
+		s.mu.Unlock()
+	}
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	s, ok := ctx.Value(scopeKey{}).(*scope)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]slog.Attr(nil), s.attrs...)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := attrsFrom(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// WithRequestID stores the id of the request ctx serves, so that code
+// below the HTTP layer can record it without depending on middleware.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the id stored with WithRequestID, or "" outside a
+// request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestRequestScopedAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := NewContext(context.Background(), slog.String("request_id", "req-1"))
+	AddAttrs(ctx, slog.Int("user_id", 7))
+	logger.With("component", "test").InfoContext(ctx, "hello")
+	logger.DebugContext(ctx, "dropped below the level")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-1" || record["user_id"] != float64(7) || record["component"] != "test" {
+		t.Errorf("Unexpected record %v", record)
+	}
+
+	AddAttrs(context.Background(), slog.String("ignored", "no scope"))
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("Expected an unknown format to fail")
+	}
+	if _, err := New(&bytes.Buffer{}, "text", "loud"); err == nil {
+		t.Error("Expected an unknown level to fail")
+	}
+}
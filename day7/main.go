@@ -1,34 +1,383 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"taskmanager/auth"
 	"taskmanager/config"
 	"taskmanager/handler"
+	"taskmanager/logging"
+	"taskmanager/metrics"
+	"taskmanager/middleware"
+	"taskmanager/migrations"
+	"taskmanager/openapi"
 	"taskmanager/repository"
 	"taskmanager/service"
+	"taskmanager/tracing"
+	"time"
 )
 
+// main exits 0 after a clean run or graceful shutdown and 1 when startup
+// fails, the server stops on its own or shutdown cannot drain in time.
 func main() {
-	config.ConnectDB()
-	db := config.DB
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "migrate":
+		err = runMigrate(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "create-admin":
+		err = runCreateAdmin(os.Args[2:])
+	default:
+		err = serve(os.Args[1:])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve runs the API until SIGINT or SIGTERM. A second signal during
+// shutdown kills the process immediately.
+func serve(args []string) (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Restore the default handlers as soon as the first signal arrives, so
+	// the second one kills the process rather than being swallowed.
+	context.AfterFunc(ctx, stop)
+
+	cfg, err := config.Load(args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", slog.String("config", cfg.String()))
+
+	tracer, closeTracer, err := openTracer(cfg.Trace)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := closeTracer(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("closing trace exporter: %w", closeErr))
+		}
+	}()
+
+	db, repos, err := openStorage(cfg, logger, tracer)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		defer func() {
+			if closeErr := db.Close(); closeErr != nil {
+				err = errors.Join(err, fmt.Errorf("closing database: %w", closeErr))
+			}
+		}()
+	}
+
+	registry := metrics.NewRegistry()
+	httpRequests := registry.Counter("taskmanager_http_requests_total",
+		"HTTP requests by ServeMux pattern and status code.", "route", "code")
+	httpDurations := registry.Histogram("taskmanager_http_request_duration_seconds",
+		"HTTP request latency by ServeMux pattern.", metrics.DefaultBuckets, "route")
+	repoDurations := registry.Histogram("taskmanager_repository_call_duration_seconds",
+		"Repository call latency by repository and method.", metrics.DefaultBuckets, "repository", "method")
+	if db != nil {
+		registry.Register(metrics.DBStats(db))
+	}
+	repos = repository.Instrument(repos, metrics.RepositoryHook(repoDurations), repository.Trace(tracer))
+
+	// Background jobs stop when ctx is cancelled and are waited for before
+	// the database closes.
+	var background workers
+	if cfg.HTTP.IdempotencyTTL > 0 {
+		background.Go(ctx, "idempotency key purge", func(ctx context.Context) {
+			every(ctx, time.Hour, func(ctx context.Context) {
+				purged, err := repos.IdempotencyKeys.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+				if err != nil {
+					logger.WarnContext(ctx, "purging expired idempotency keys failed", slog.Any("error", err))
+					return
+				}
+				logger.DebugContext(ctx, "purged expired idempotency keys", slog.Int("purged", purged))
+			})
+		})
+	}
+	background.Go(ctx, "session purge", func(ctx context.Context) {
+		every(ctx, time.Hour, func(ctx context.Context) {
+			purged, err := repos.Sessions.PurgeExpiredSessions(ctx, time.Now())
+			if err != nil {
+				logger.WarnContext(ctx, "purging expired sessions failed", slog.Any("error", err))
+				return
+			}
+			logger.DebugContext(ctx, "purged expired sessions", slog.Int("purged", purged))
+		})
+	})
+	if retention := cfg.Trash.Retention.Std(); retention > 0 {
+		background.Go(ctx, "trash purge", func(ctx context.Context) {
+			every(ctx, time.Hour, func(ctx context.Context) {
+				purgeTrash(ctx, repos, time.Now().UTC().Add(-retention), logger)
+			})
+		})
+	}
 
-	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userService)
+	signer, err := newSigner(cfg.Auth, logger)
+	if err != nil {
+		return err
+	}
+	authService := service.TraceAuth(service.NewAuthService(repos.Users, repos.APIKeys, repos.Sessions, repos.Tx, signer, logger), tracer)
+	authHandler := handler.NewAuthHandler(authService, logger)
 
-	taskRepo := repository.NewTaskRepository(db)
-	taskService := service.NewTaskService(taskRepo)
-	taskHandler := handler.NewTaskHandler(taskService)
+	userService := service.TraceUsers(service.NewUserService(repos.Users, repos.Tasks, repos.Sessions, repos.Audit, repos.Tx, logger), tracer)
+	userHandler := handler.NewUserHandler(userService, logger)
+
+	taskService := service.TraceTasks(service.NewTaskService(repos.Tasks, repos.Users, repos.Audit, repos.Tx, logger), tracer)
+	taskHandler := handler.NewTaskHandler(taskService, logger)
+
+	auditService := service.TraceAudit(service.NewAuditService(repos.Audit), tracer)
+	auditHandler := handler.NewAuditHandler(auditService, logger)
+
+	checks, err := readinessChecks(cfg, db)
+	if err != nil {
+		return err
+	}
+	health := handler.NewHealthHandler(cfg.HTTP.ReadyTimeout.Std(), logger, checks...)
+
+	doc, err := openapi.Load()
+	if err != nil {
+		return err
+	}
+	docs := handler.NewDocsHandler(doc)
+
+	// Rate limits and idempotency keys run after authentication so they can
+	// key on the user. A looser limit per address runs before it, so that
+	// requests failing authentication are limited as well.
+	limits := middleware.NewMemoryRateLimitStore()
+	perIP := middleware.Rate{Requests: cfg.RateLimit.PerIP.Requests, Per: cfg.RateLimit.PerIP.Per.Std()}
+	idempotent := map[string]bool{"POST /tasks": true, "POST /users": true}
+	limited := func(pattern string, h http.HandlerFunc) http.Handler {
+		rate := cfg.RateLimit.RateFor(pattern)
+		limit := middleware.RateLimit(limits, pattern, middleware.Rate{Requests: rate.Requests, Per: rate.Per.Std()}, logger)
+		next := handler.ValidateBody(doc, pattern, h)
+		if idempotent[pattern] {
+			// Twice the route's deadline leaves time to store the outcome.
+			lease := 2 * cfg.HTTP.TimeoutFor(pattern)
+			next = middleware.Idempotency(repos.IdempotencyKeys, pattern, cfg.HTTP.IdempotencyTTL.Std(), lease, logger)(next)
+		}
+		return limit(next)
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /users/{id}", userHandler.GetUser)
-	mux.HandleFunc("POST /users", userHandler.CreateUser)
-	mux.HandleFunc("POST /tasks", taskHandler.CreateTask)
-	mux.HandleFunc("GET /tasks/{id}", taskHandler.GetUserTasks)
+	route := func(pattern string, h http.HandlerFunc) {
+		byIP := middleware.RateLimitByIP(limits, pattern, perIP, logger)
+		mux.Handle(pattern, middleware.Route(pattern,
+			handler.WithTimeout(cfg.HTTP.TimeoutFor(pattern), byIP(handler.RequireAuth(authService, logger, limited(pattern, h))))))
+	}
+	public := func(pattern string, h http.HandlerFunc) {
+		byIP := middleware.RateLimitByIP(limits, pattern, perIP, logger)
+		mux.Handle(pattern, middleware.Route(pattern,
+			handler.WithTimeout(cfg.HTTP.TimeoutFor(pattern), byIP(handler.OptionalAuth(authService, logger, limited(pattern, h))))))
+	}
+	mux.HandleFunc("GET /healthz", health.Healthz)
+	mux.HandleFunc("GET /readyz", health.Readyz)
+	mux.HandleFunc("GET /version", health.Version)
+	mux.Handle("GET /metrics", registry)
+	mux.HandleFunc("GET /openapi.json", docs.Spec)
+	mux.HandleFunc("GET /docs", docs.Docs)
+	public("POST /auth/login", authHandler.Login)
+	route("POST /auth/logout", authHandler.Logout)
+	public("POST /users", userHandler.CreateUser)
+	route("GET /users/{id}", userHandler.GetUser)
+	route("DELETE /users/{id}", userHandler.DeleteUser)
+	route("POST /users/{id}/restore", userHandler.RestoreUser)
+	route("PUT /users/{id}/password", userHandler.ChangePassword)
+	route("POST /users/{id}/api-keys", authHandler.CreateAPIKey)
+	route("GET /users/{id}/tasks", taskHandler.GetUserTasks)
+	route("POST /tasks", taskHandler.CreateTask)
+	route("POST /tasks:batch", taskHandler.BatchTasks)
+	route("GET /tasks/{id}", taskHandler.GetTask)
+	route("PUT /tasks/{id}", taskHandler.UpdateTask)
+	route("PATCH /tasks/{id}", taskHandler.PatchTask)
+	route("DELETE /tasks/{id}", taskHandler.DeleteTask)
+	route("POST /tasks/{id}/transition", taskHandler.TransitionTask)
+	route("POST /tasks/{id}/restore", taskHandler.RestoreTask)
+	route("GET /trash", taskHandler.GetTrash)
+	route("GET /audit", auditHandler.ListAuditEvents)
+
+	server := &http.Server{
+		Addr: cfg.HTTP.Addr,
+		// The request ID seeds the access log, the span is finished after
+		// Recover turns a panic into a 500, and Metrics wraps the mux
+		// directly so it sees the matched pattern.
+		Handler: middleware.Chain(mux,
+			middleware.WithRequestID(),
+			middleware.AccessLog(logger),
+			middleware.Trace(tracer),
+			middleware.Recover(logger),
+			middleware.CORS(cfg.HTTP.CORSOrigins),
+			middleware.LimitBody(int64(cfg.HTTP.MaxBodyBytes)),
+			middleware.Gzip(),
+			middleware.Metrics(httpRequests, httpDurations),
+		),
+		ReadTimeout:  cfg.HTTP.ReadTimeout.Std(),
+		WriteTimeout: cfg.HTTP.WriteTimeout.Std(),
+		IdleTimeout:  cfg.HTTP.IdleTimeout.Std(),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
 
-	fmt.Println("Server running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", mux))
+	ln, err := net.Listen("tcp", cfg.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("server running", slog.String("addr", ln.Addr().String()))
+
+	err = runServer(ctx, server, ln, cfg.HTTP.ShutdownDelay.Std(), cfg.HTTP.ShutdownTimeout.Std(), health.ShuttingDown)
+	// runServer can also return without a signal, when serving fails; the
+	// background jobs still need ctx cancelled to stop.
+	stop()
+	if waitErr := background.Wait(cfg.HTTP.ShutdownTimeout.Std()); waitErr != nil {
+		err = errors.Join(err, waitErr)
+	}
+	if err == nil {
+		logger.Info("server stopped")
+	}
+	return err
+}
+
+// openStorage connects to the configured backend, applying migrations first
+// when auto_migrate is set. The returned *sql.DB is nil for memory storage.
+func openStorage(cfg *config.Config, logger *slog.Logger, tracer *tracing.Tracer) (*sql.DB, *repository.Repositories, error) {
+	var db *sql.DB
+	if cfg.Storage != repository.BackendMemory {
+		dialect, err := repository.ParseDialect(cfg.Storage)
+		if err != nil {
+			return nil, nil, err
+		}
+		db, err = config.ConnectDB(dialect.DriverName(), cfg.DB)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Info("connected to database", slog.String("dialect", string(dialect)))
+
+		if cfg.DB.AutoMigrate {
+			migrator, err := migrations.NewMigrator(db, dialect, os.Stdout, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := migrator.Up(0); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	repos, err := repository.New(cfg.Storage, db, repository.Options{
+		Logger:    logger,
+		SlowQuery: cfg.Log.SlowQuery.Std(),
+		Tracer:    tracer,
+		TxRetries: cfg.DB.TxRetries,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, repos, nil
+}
+
+// purgeTrash removes the tasks and users deleted before before for good.
+// Tasks go first so that their owners can follow in the same run. Each
+// purge runs through repos.Tx so that it is serialized with the services'
+// transactions.
+func purgeTrash(ctx context.Context, repos *repository.Repositories, before time.Time, logger *slog.Logger) {
+	var tasks, users int
+	err := repos.Tx.Run(ctx, func(ctx context.Context) (err error) {
+		tasks, err = repos.Tasks.PurgeDeletedTasks(ctx, before)
+		return err
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "purging deleted tasks failed", slog.Any("error", err))
+		return
+	}
+	err = repos.Tx.Run(ctx, func(ctx context.Context) (err error) {
+		users, err = repos.Users.PurgeDeletedUsers(ctx, before)
+		return err
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "purging deleted users failed", slog.Any("error", err))
+		return
+	}
+	if tasks > 0 || users > 0 {
+		logger.InfoContext(ctx, "purged trash", slog.Int("tasks", tasks), slog.Int("users", users))
+	}
+}
+
+// openTracer builds the tracer for the configured exporter, or returns a
+// nil tracer when tracing is off. The returned func closes the exporter.
+func openTracer(cfg config.TraceConfig) (*tracing.Tracer, func() error, error) {
+	var exporter *tracing.WriterExporter
+	switch cfg.Exporter {
+	case "stdout":
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		var err error
+		exporter, err = tracing.OpenFileExporter(cfg.File)
+		if err != nil {
+			return nil, nil, fmt.Errorf("trace file: %w", err)
+		}
+	default:
+		return nil, func() error { return nil }, nil
+	}
+	return tracing.NewTracer(exporter), exporter.Close, nil
+}
+
+// readinessChecks verifies that the database answers and that its schema is
+// at the version this binary expects. Memory storage has nothing to check.
+func readinessChecks(cfg *config.Config, db *sql.DB) ([]handler.ReadinessCheck, error) {
+	if db == nil {
+		return nil, nil
+	}
+	dialect, err := repository.ParseDialect(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrations.NewMigrator(db, dialect, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return []handler.ReadinessCheck{
+		{Name: "database", Check: func(ctx context.Context) error {
+			return db.PingContext(ctx)
+		}},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			version, err := migrator.VersionContext(ctx)
+			if err != nil {
+				return fmt.Errorf("reading the schema version: %w", err)
+			}
+			if version != migrator.Latest() {
+				return fmt.Errorf("schema is at version %d, expected %d", version, migrator.Latest())
+			}
+			return nil
+		}},
+	}, nil
+}
 
+func newSigner(cfg config.AuthConfig, logger *slog.Logger) (*auth.Signer, error) {
+	secret := []byte(cfg.TokenSecret.Reveal())
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		logger.Warn("auth.token_secret is not set; access tokens will not survive a restart")
+	}
+	return auth.NewSigner(secret, cfg.TokenTTL.Std()), nil
 }
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+)
+
+type dbStats struct {
+	db *sql.DB
+}
+
+// DBStats reports the connection pool statistics of db at scrape time.
+func DBStats(db *sql.DB) Collector {
+	return dbStats{db: db}
+}
+
+func (c dbStats) Collect(w io.Writer) {
+	s := c.db.Stats()
+
+	gauge := func(name, help string, v float64) {
+		f := newFamily(name, help, nil)
+		f.header(w, "gauge")
+		f.sample(w, "", "", "", v)
+	}
+	counter := func(name, help string, v float64) {
+		f := newFamily(name, help, nil)
+		f.header(w, "counter")
+		f.sample(w, "", "", "", v)
+	}
+
+	gauge("taskmanager_db_max_open_connections", "Maximum number of open connections to the database.", float64(s.MaxOpenConnections))
+	gauge("taskmanager_db_open_connections", "Established connections, both in use and idle.", float64(s.OpenConnections))
+	gauge("taskmanager_db_in_use_connections", "Connections currently in use.", float64(s.InUse))
+	gauge("taskmanager_db_idle_connections", "Idle connections.", float64(s.Idle))
+	counter("taskmanager_db_wait_count_total", "Connections waited for.", float64(s.WaitCount))
+	counter("taskmanager_db_wait_duration_seconds_total", "Time spent waiting for a connection.", s.WaitDuration.Seconds())
+	counter("taskmanager_db_max_idle_closed_total", "Connections closed due to max_idle_conns.", float64(s.MaxIdleClosed))
+	counter("taskmanager_db_max_idle_time_closed_total", "Connections closed due to conn_max_idle_time.", float64(s.MaxIdleTimeClosed))
+	counter("taskmanager_db_max_lifetime_closed_total", "Connections closed due to conn_max_lifetime.", float64(s.MaxLifetimeClosed))
+}
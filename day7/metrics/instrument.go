@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"context"
+	"taskmanager/repository"
+	"time"
+)
+
+// RepositoryHook records how long each repository method takes.
+func RepositoryHook(durations *HistogramVec) repository.Hook {
+	return func(ctx context.Context, op repository.Operation) (context.Context, func(error)) {
+		start := time.Now()
+		return ctx, func(error) {
+			durations.Observe(time.Since(start).Seconds(), op.Repository, op.Method)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taskmanager/repository"
+	"testing"
+)
+
+func TestTextFormat(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.Counter("test_requests_total", "Requests served.", "route", "code")
+	durations := reg.Histogram("test_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+
+	requests.Inc("GET /tasks/{id}", "200")
+	requests.Inc("GET /tasks/{id}", "200")
+	requests.Inc(`say "hi"`, "404")
+	durations.Observe(0.05, "GET /tasks/{id}")
+	durations.Observe(0.5, "GET /tasks/{id}")
+	durations.Observe(3, "GET /tasks/{id}")
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{route="GET /tasks/{id}",code="200"} 2` + "\n",
+		`test_requests_total{route="say \"hi\"",code="404"} 1` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="GET /tasks/{id}",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{route="GET /tasks/{id}",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="GET /tasks/{id}",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{route="GET /tasks/{id}"} 3.55` + "\n",
+		`test_duration_seconds_count{route="GET /tasks/{id}"} 3` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in:\n%s", want, body)
+		}
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %s", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestRepositoryHook(t *testing.T) {
+	reg := NewRegistry()
+	durations := reg.Histogram("test_repository_seconds", "Repository latency.", DefaultBuckets, "repository", "method")
+
+	_, done := RepositoryHook(durations)(context.Background(), repository.Operation{Repository: "tasks", Method: "ListTasks"})
+	done(errors.New("failed calls are timed too"))
+
+	var buf strings.Builder
+	reg.Write(&buf)
+	if !strings.Contains(buf.String(), `test_repository_seconds_count{repository="tasks",method="ListTasks"} 1`) {
+		t.Errorf("Expected one ListTasks observation, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// LimitBody rejects request bodies larger than limit bytes. Bodies that
+// announce their size are refused up front with 413; others fail to read
+// past the limit. A limit of zero or less disables the check.
+func LimitBody(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeProblem(w, r, http.StatusRequestEntityTooLarge, "body_too_large",
+					fmt.Sprintf("request body must not exceed %d bytes", limit))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
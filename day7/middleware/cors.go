@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+var (
+	corsMethods       = "GET, POST, PUT, PATCH, DELETE"
+	corsHeaders       = "Authorization, Content-Type, Idempotency-Key, If-Match, " + RequestIDHeader
+	corsExposeHeaders = "ETag, Idempotent-Replayed, Link, Location, " +
+		"RateLimit-Limit, RateLimit-Policy, RateLimit-Remaining, RateLimit-Reset, Retry-After, " + RequestIDHeader
+)
+
+// CORS lets browsers on the listed origins call the API. "*" allows any
+// origin; credentials are never allowed since the API uses bearer tokens.
+// An empty list disables CORS entirely.
+func CORS(origins []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		anyOrigin := slices.Contains(origins, "*")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(origins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", corsMethods)
+				h.Set("Access-Control-Allow-Headers", corsHeaders)
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
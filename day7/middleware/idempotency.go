@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"time"
+)
+
+// IdempotencyStore keeps the responses replayed by Idempotency.
+// repository.IdempotencyRepository implements it.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error
+}
+
+// maxIdempotencyKey is long enough for any UUID or ULID encoding.
+const maxIdempotencyKey = 255
+
+// Idempotency replays the first response for an Idempotency-Key header to
+// retries with the same key and body for ttl. A different body gets 422 and
+// a retry while the first request runs gets 409; server errors and empty
+// responses are not kept. A running request holds its key for lease, which
+// should outlast the wrapped handler. A zero ttl disables it. Keys belong
+// to the authenticated user, so it must run inside the authentication
+// middleware.
+func Idempotency(store IdempotencyStore, pattern string, ttl, lease time.Duration, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		if ttl <= 0 {
+			return next
+		}
+		if lease <= 0 || lease > ttl {
+			lease = ttl
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKey {
+				writeProblem(w, r, http.StatusBadRequest, "invalid_idempotency_key",
+					fmt.Sprintf("Idempotency-Key must not exceed %d characters", maxIdempotencyKey))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeProblem(w, r, http.StatusRequestEntityTooLarge, "body_too_large",
+						fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+					return
+				}
+				writeProblem(w, r, http.StatusBadRequest, "invalid_body", "request body could not be read")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			record := &models.IdempotencyRecord{
+				Key:         digest(pattern, idempotencyScope(r), key),
+				Owner:       newRandomID(),
+				RequestHash: digest(string(body)),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(lease),
+			}
+			existing, err := store.ReserveIdempotencyKey(r.Context(), record)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "idempotency store failed", slog.Any("error", err))
+				writeProblem(w, r, http.StatusServiceUnavailable, "idempotency_unavailable",
+					"the Idempotency-Key could not be checked; retry the request later")
+				return
+			}
+			if existing != nil {
+				replay(w, r, existing, record.RequestHash)
+				return
+			}
+
+			// The key is released even if the client has gone away, since that
+			// is when it is most likely to retry.
+			ctx := context.WithoutCancel(r.Context())
+			rec := &responseCapture{ResponseWriter: w, before: w.Header().Clone()}
+			defer func() {
+				if p := recover(); p != nil {
+					store.ReleaseIdempotencyKey(ctx, record)
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+
+			// A handler whose client went away writes nothing, and its work
+			// has most likely been rolled back, so there is nothing to replay.
+			if rec.status == 0 || r.Context().Err() != nil || rec.status >= http.StatusInternalServerError {
+				if err := store.ReleaseIdempotencyKey(ctx, record); err != nil {
+					logger.WarnContext(ctx, "releasing idempotency key failed", slog.Any("error", err))
+				}
+				return
+			}
+			record.Status, record.Header, record.Body = rec.status, rec.header, rec.body.Bytes()
+			record.ExpiresAt = now.Add(ttl)
+			if err := store.CompleteIdempotencyKey(ctx, record); err != nil {
+				logger.WarnContext(ctx, "storing idempotent response failed", slog.Any("error", err))
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, record *models.IdempotencyRecord, requestHash string) {
+	switch {
+	case record.RequestHash != requestHash:
+		writeProblem(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused",
+			"Idempotency-Key was already used for a request with a different body")
+	case !record.Completed():
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, http.StatusConflict, "idempotency_key_in_use",
+			"a request with this Idempotency-Key is still being processed")
+	default:
+		maps.Copy(w.Header(), record.Header)
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.Status)
+		w.Write(record.Body)
+	}
+}
+
+// idempotencyScope keeps one caller from replaying another's responses.
+func idempotencyScope(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return "user:" + strconv.Itoa(p.UserID)
+	}
+	return "ip:" + remoteIP(r)
+}
+
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		io.WriteString(h, strconv.Itoa(len(part)))
+		io.WriteString(h, ":")
+		io.WriteString(h, part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseCapture copies a response as the handler writes it: its status,
+// the headers the handler set, and its body.
+type responseCapture struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader records the headers before passing the response on, since
+// outer middleware such as Gzip add their own from here.
+func (w *responseCapture) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = http.Header{}
+		for k, v := range w.Header() {
+			if !slices.Equal(w.before[k], v) {
+				w.header[k] = slices.Clone(v)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseCapture) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"taskmanager/metrics"
+	"time"
+)
+
+// Metrics counts requests and records their latency per ServeMux pattern.
+func Metrics(requests *metrics.CounterVec, durations *metrics.HistogramVec) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			// A panic leaves the handler before panicked is cleared; Recover
+			// further out turns it into a 500, so count it as one.
+			panicked := true
+			defer func() {
+				status := rec.status
+				switch {
+				case panicked:
+					status = http.StatusInternalServerError
+				case status == 0:
+					status = http.StatusOK
+				}
+
+				route := r.Pattern
+				if route == "" {
+					route = "unmatched"
+				}
+				requests.Inc(route, strconv.Itoa(status))
+				durations.Observe(time.Since(start).Seconds(), route)
+			}()
+
+			next.ServeHTTP(rec, r)
+			panicked = false
+		})
+	}
+}
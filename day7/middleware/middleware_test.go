@@ -0,0 +1,515 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"taskmanager/auth"
+	"taskmanager/logging"
+	"taskmanager/metrics"
+	"taskmanager/models"
+	"taskmanager/repository"
+	"taskmanager/tracing"
+	"testing"
+	"time"
+)
+
+func TestChainRunsOutermostFirst(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { order = append(order, "handler") }),
+		tag("first"), tag("second"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "first,second,handler" {
+		t.Errorf("Unexpected order %s", got)
+	}
+}
+
+func textLogger(t *testing.T, w io.Writer) *slog.Logger {
+	t.Helper()
+
+	logger, err := logging.New(w, "text", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+func TestRecoverReturns500AndLogs(t *testing.T) {
+	var logs bytes.Buffer
+	logger := textLogger(t, &logs)
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }),
+		WithRequestID(), AccessLog(logger), Recover(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Expected a 500 problem, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if strings.Contains(rec.Body.String(), "boom") {
+		t.Errorf("Expected the panic value to stay out of the response, got %s", rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "panic=boom") || !strings.Contains(logs.String(), "request_id=req-123") {
+		t.Errorf("Expected the panic and request id to be logged, got %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "status=500") {
+		t.Errorf("Expected the access log to record the 500, got %s", logs.String())
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := WithRequestID()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		incoming string
+		keep     bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"bad id\nwith newline", false},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.incoming != "" {
+			req.Header.Set(RequestIDHeader, tt.incoming)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(RequestIDHeader)
+		if got == "" || got != seen {
+			t.Errorf("%q: expected the header to match the context id, got %q and %q", tt.incoming, got, seen)
+		}
+		if (got == tt.incoming) != tt.keep {
+			t.Errorf("%q: keep=%v, got %q", tt.incoming, tt.keep, got)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logger := textLogger(t, &logs)
+	h := Chain(Route("POST /tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "inside")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})), WithRequestID(), AccessLog(logger))
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks?x=1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected the handler and access log records, got %q", logs.String())
+	}
+	if !strings.Contains(lines[0], "request_id=req-1") || !strings.Contains(lines[0], `route="POST /tasks"`) {
+		t.Errorf("Expected request attributes on the handler record, got %q", lines[0])
+	}
+	line := lines[1]
+	for _, want := range []string{"method=POST", `path="/tasks?x=1"`, "status=201", "bytes=5", "request_id=req-1", `route="POST /tasks"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %q in %q", want, line)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	called := false
+	h := CORS([]string{"https://app.example.com"})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/tasks", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || called {
+		t.Errorf("Expected the preflight to be answered directly, got %d called=%v", rec.Code, called)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Unexpected preflight headers %v", rec.Header())
+	}
+	for _, name := range []string{"Authorization", "Idempotency-Key", "If-Match"} {
+		if !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), name) {
+			t.Errorf("Expected the preflight to allow %s, got %v", name, rec.Header())
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	for _, name := range []string{"ETag", "Idempotent-Replayed", "Location", "RateLimit-Remaining", "Retry-After"} {
+		if !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), name) {
+			t.Errorf("Expected %s to be exposed, got %v", name, rec.Header())
+		}
+	}
+	called = false
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || !called {
+		t.Errorf("Expected an unlisted origin to get no CORS headers, got %v", rec.Header())
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	var readErr error
+	h := LimitBody(8)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("0123456789")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("0123456789"))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if readErr == nil {
+		t.Error("Expected reading past the limit to fail")
+	}
+}
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat(`{"title":"compress me"}`, 100)
+	h := Gzip()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"3"`)
+		io.WriteString(w, body)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip response, got %v", rec.Header())
+	}
+	if got := rec.Header().Get("ETag"); got != `"3-gzip"` {
+		t.Errorf(`Expected the ETag "3-gzip", got %q`, got)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip stream: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("Unexpected decompressed body %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != body || rec.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected an uncompressed response with the handler's ETag when gzip is refused")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/empty", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+		t.Errorf("Expected a bare 204, got %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestMetricsUsesRoutePattern(t *testing.T) {
+	reg := metrics.NewRegistry()
+	requests := reg.Counter("requests_total", "Requests.", "route", "code")
+	durations := reg.Histogram("duration_seconds", "Latency.", metrics.DefaultBuckets, "route")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/{id}", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("POST /boom", func(http.ResponseWriter, *http.Request) { panic("boom") })
+	h := Chain(mux, Recover(logging.Discard()), Metrics(requests, durations))
+
+	for _, target := range []string{"/tasks/1", "/tasks/2", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/boom", nil))
+
+	var buf strings.Builder
+	reg.Write(&buf)
+	for _, want := range []string{
+		`requests_total{route="GET /tasks/{id}",code="200"} 2`,
+		`requests_total{route="unmatched",code="404"} 1`,
+		`requests_total{route="POST /boom",code="500"} 1`,
+		`duration_seconds_count{route="GET /tasks/{id}"} 2`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestTraceContinuesCallerTrace(t *testing.T) {
+	spans := &tracing.Recorder{}
+	var logs bytes.Buffer
+	logger := textLogger(t, &logs)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /tasks/{id}", Route("GET /tasks/{id}", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	mux.Handle("POST /boom", Route("POST /boom", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })))
+	h := Chain(mux, WithRequestID(), AccessLog(logger), Trace(tracing.NewTracer(spans)), Recover(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/boom", nil))
+
+	got := spans.Spans()
+	if len(got) != 2 {
+		t.Fatalf("Expected a span per request, got %+v", got)
+	}
+	span := got[0]
+	if span.Name != "GET /tasks/{id}" || span.Kind != tracing.KindServer || span.ParentSpanID != "00f067aa0ba902b7" ||
+		span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Attributes["http.status_code"] != int64(200) {
+		t.Errorf("Unexpected span %+v", span)
+	}
+	if !strings.Contains(logs.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("Expected the trace id in the access log, got %s", logs.String())
+	}
+	if got[1].Name != "POST /boom" || got[1].Status != "error" || got[1].ParentSpanID != "" {
+		t.Errorf("Expected a failed root span for the panic, got %+v", got[1])
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	h := RateLimit(store, "POST /tasks", Rate{Requests: 2, Per: time.Minute}, textLogger(t, io.Discard))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) }))
+	send := func(remoteAddr string, userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != 0 {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{UserID: userID}))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := send("10.0.0.1:5000", 0)
+		if rec.Code != http.StatusCreated || rec.Header().Get("RateLimit-Remaining") != remaining {
+			t.Fatalf("Request %d: expected 201 with %s remaining, got %d with %q", i+1, remaining, rec.Code, rec.Header().Get("RateLimit-Remaining"))
+		}
+	}
+	rec := send("10.0.0.1:6000", 0)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected 429 with Retry-After 30, got %d with %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Errorf("Unexpected policy %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"rate_limited"`) {
+		t.Errorf("Expected a rate_limited problem, got %s", rec.Body)
+	}
+
+	// Authenticated requests get a bucket per user, wherever they come from.
+	if rec := send("10.0.0.1:5000", 7); rec.Code != http.StatusCreated {
+		t.Errorf("Expected user 7 to have its own bucket, got %d", rec.Code)
+	}
+	if rec := send("10.0.0.2:5000", 0); rec.Code != http.StatusCreated {
+		t.Errorf("Expected another IP to have its own bucket, got %d", rec.Code)
+	}
+
+	unlimited := RateLimit(store, "GET /tasks", Rate{}, textLogger(t, io.Discard))(http.NotFoundHandler())
+	rec = httptest.NewRecorder()
+	unlimited.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if rec.Header().Get("RateLimit-Limit") != "" {
+		t.Error("Expected a zero rate to disable the limit")
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	logger := textLogger(t, io.Discard)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	h := RateLimitByIP(store, "GET /tasks", Rate{Requests: 2, Per: time.Minute}, logger)(
+		RateLimit(store, "GET /tasks", Rate{Requests: 1, Per: time.Minute}, logger)(ok))
+	send := func(userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		if userID != 0 {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{UserID: userID}))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The anonymous request takes a token from both buckets without them
+	// sharing one, and the user's comes out of the address's budget too.
+	if rec := send(0); rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("Expected 204 with the inner limit's headers, got %d with %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec := send(7); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected user 7 to get through, got %d", rec.Code)
+	}
+	rec := send(8)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("RateLimit-Limit") != "2" {
+		t.Errorf("Expected the address's limit to reject user 8, got %d with %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	rate := Rate{Requests: 3, Per: 3 * time.Second}
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for range 3 {
+		store.Take(ctx, "k", rate, now)
+	}
+	if d, _ := store.Take(ctx, "k", rate, now); d.Allowed || d.RetryAfter != time.Second || d.Reset != 3*time.Second {
+		t.Errorf("Expected an empty bucket, got %+v", d)
+	}
+	if d, _ := store.Take(ctx, "k", rate, now.Add(1500*time.Millisecond)); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Expected one token after 1.5s, got %+v", d)
+	}
+
+	store.Take(ctx, "idle", rate, now)
+	store.Take(ctx, "k", rate, now.Add(2*time.Minute))
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("Expected the refilled bucket to be swept")
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	store := repository.NewMemoryIdempotencyRepository()
+	calls := 0
+	failing := false
+	h := Idempotency(store, "POST /tasks", time.Hour, time.Minute, textLogger(t, io.Discard))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if failing {
+				http.Error(w, "database is down", http.StatusInternalServerError)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Location", "/tasks/"+strconv.Itoa(calls))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "task %d from %s", calls, body)
+		}))
+	send := func(key, body string, userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		if userID != 0 {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{UserID: userID}))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("k1", `{"title":"a"}`, 1)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("Expected the first request to run, got %d after %d calls", first.Code, calls)
+	}
+	retry := send("k1", `{"title":"a"}`, 1)
+	if retry.Code != http.StatusCreated || calls != 1 || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the retry to be replayed, got %d %q after %d calls", retry.Code, retry.Body, calls)
+	}
+	if retry.Header().Get("Location") != "/tasks/1" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected the stored headers to be replayed, got %v", retry.Header())
+	}
+
+	if rec := send("k1", `{"title":"b"}`, 1); rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "idempotency_key_reused") {
+		t.Errorf("Expected a different body to be rejected, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := send("k1", `{"title":"a"}`, 2); rec.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("Expected another user's key to be separate, got %d after %d calls", rec.Code, calls)
+	}
+	send("", `{"title":"a"}`, 1)
+	send("", `{"title":"a"}`, 1)
+	if calls != 4 {
+		t.Errorf("Expected requests without a key to always run, got %d calls", calls)
+	}
+
+	failing = true
+	send("k2", `{}`, 1)
+	failing = false
+	if rec := send("k2", `{}`, 1); rec.Code != http.StatusCreated || calls != 6 {
+		t.Errorf("Expected a server error not to be replayed, got %d after %d calls", rec.Code, calls)
+	}
+
+	// A handler that sees its client go away writes nothing, as writeError
+	// does for context.Canceled.
+	reqCtx, cancel := context.WithCancel(auth.WithPrincipal(context.Background(), auth.Principal{UserID: 1}))
+	abandon := Idempotency(store, "POST /tasks", time.Hour, time.Minute, textLogger(t, io.Discard))(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) { cancel() }))
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{}`)).WithContext(reqCtx)
+	req.Header.Set("Idempotency-Key", "k3")
+	abandon.ServeHTTP(httptest.NewRecorder(), req)
+	if rec := send("k3", `{}`, 1); rec.Code != http.StatusCreated || calls != 7 {
+		t.Errorf("Expected an abandoned request not to be replayed, got %d after %d calls", rec.Code, calls)
+	}
+
+	ctx := context.Background()
+	store.ReserveIdempotencyKey(ctx, &models.IdempotencyRecord{
+		Key: digest("POST /tasks", "ip:192.0.2.1", "busy"), RequestHash: digest("{}"),
+		CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if rec := send("busy", "{}", 0); rec.Code != http.StatusConflict || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 while the first request runs, got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("{}"))
+	req.RemoteAddr = "198.51.100.7:4000"
+	req.Header.Set("Idempotency-Key", "busy")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected anonymous callers on another address to have their own keys, got %d", rec.Code)
+	}
+
+	store.ReserveIdempotencyKey(ctx, &models.IdempotencyRecord{
+		Key: digest("POST /tasks", "user:1", "crashed"), RequestHash: digest("{}"),
+		CreatedAt: time.Now().Add(-2 * time.Minute), ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if rec := send("crashed", "{}", 1); rec.Code != http.StatusCreated {
+		t.Errorf("Expected a reservation past its lease to be taken over, got %d", rec.Code)
+	}
+	if rec := send(strings.Repeat("k", 256), "{}", 0); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an overlong key to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"taskmanager/auth"
+	"time"
+)
+
+// Rate allows Requests per Per, in bursts of up to Requests.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// RateLimitDecision is the outcome of taking a token from a bucket.
+type RateLimitDecision struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until a token is available again; zero when
+	// the request was allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full.
+	Reset time.Duration
+}
+
+// RateLimitStore keeps a token bucket per key. Implementations shared by
+// several instances of the server let them enforce one budget.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rate Rate, now time.Time) (RateLimitDecision, error)
+}
+
+// RateLimit gives each user, or remote IP when anonymous, a token bucket of
+// rate on the route it wraps and answers 429 once it is empty. A zero rate
+// disables the limit; a failing store lets requests through.
+// It must run inside the authentication middleware.
+func RateLimit(store RateLimitStore, pattern string, rate Rate, logger *slog.Logger) Middleware {
+	return rateLimit(store, pattern, rate, logger, clientKey)
+}
+
+// RateLimitByIP is RateLimit keyed on the remote IP alone, with buckets of
+// its own. It runs before authentication; a RateLimit inside it overwrites
+// its RateLimit headers.
+func RateLimitByIP(store RateLimitStore, pattern string, rate Rate, logger *slog.Logger) Middleware {
+	return rateLimit(store, pattern, rate, logger, func(r *http.Request) string {
+		return "addr:" + remoteIP(r)
+	})
+}
+
+func rateLimit(store RateLimitStore, pattern string, rate Rate, logger *slog.Logger, key func(r *http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if rate.Requests <= 0 || rate.Per <= 0 {
+			return next
+		}
+		policy := fmt.Sprintf("%d;w=%d", rate.Requests, int(rate.Per.Seconds()))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := store.Take(r.Context(), pattern+" "+key(r), rate, time.Now())
+			if err != nil {
+				logger.WarnContext(r.Context(), "rate limit store failed", slog.Any("error", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(rate.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+			if !d.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+				writeProblem(w, r, http.StatusTooManyRequests, "rate_limited",
+					fmt.Sprintf("rate limit of %d requests per %s exceeded", rate.Requests, rate.Per))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return "user:" + strconv.Itoa(p.UserID)
+	}
+	return "ip:" + remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process memory. Buckets that have
+// refilled are dropped on a periodic sweep, so idle clients cost nothing.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+const sweepInterval = time.Minute
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rate Rate, now time.Time) (RateLimitDecision, error) {
+	if err := ctx.Err(); err != nil {
+		return RateLimitDecision{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+
+	capacity := float64(rate.Requests)
+	perToken := rate.Per / time.Duration(rate.Requests)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(capacity, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+	b.updated = now
+
+	d := RateLimitDecision{Allowed: b.tokens >= 1}
+	if d.Allowed {
+		b.tokens--
+	} else {
+		d.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	b.full = now.Add(d.Reset)
+	return d, nil
+}
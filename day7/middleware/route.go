@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"taskmanager/logging"
+	"taskmanager/tracing"
+)
+
+// Route records the ServeMux pattern a handler is registered under in the
+// request's log attributes and as the name of its server span.
+func Route(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.AddAttrs(r.Context(), slog.String("route", pattern))
+		if span := tracing.SpanFromContext(r.Context()); span != nil {
+			span.SetName(pattern)
+			span.SetAttributes(slog.String("http.route", pattern))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
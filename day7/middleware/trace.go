@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"taskmanager/logging"
+	"taskmanager/tracing"
+)
+
+// Trace starts a server span for each request, continuing the caller's
+// trace from a valid traceparent header.
+func Trace(tracer *tracing.Tracer) Middleware {
+	return func(next http.Handler) http.Handler {
+		if tracer == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(tracing.Extract(r.Context(), r.Header), r.Method, tracing.KindServer,
+				slog.String("http.method", r.Method),
+				slog.String("url.path", r.URL.Path))
+			logging.AddAttrs(ctx, slog.String("trace_id", span.SpanContext().TraceID.String()))
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			span.SetAttributes(slog.Int("http.status_code", rec.status), slog.Int("http.response_size", rec.bytes))
+			if rec.status >= http.StatusInternalServerError {
+				span.SetError(http.StatusText(rec.status))
+			}
+			span.End()
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"taskmanager/config"
+	"taskmanager/migrations"
+	"taskmanager/repository"
+)
+
+const migrateUsage = "usage: taskmanager migrate [flags] up|down|status|baseline"
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("taskmanager migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the SQL that would run without executing it")
+	steps := fs.Int("steps", 0, "number of migrations to apply (up: 0 = all, down: 0 = one)")
+	version := fs.Int("version", 0, "baseline: the migration an existing schema already matches")
+
+	cfg, err := config.LoadFlags(fs, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	if cfg.Storage == repository.BackendMemory {
+		return fmt.Errorf("migrations need a SQL storage backend, not %s", cfg.Storage)
+	}
+	dialect, err := repository.ParseDialect(cfg.Storage)
+	if err != nil {
+		return err
+	}
+
+	db, err := config.ConnectDB(dialect.DriverName(), cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, dialect, os.Stdout, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		return migrator.Up(*steps)
+	case "down":
+		return migrator.Down(*steps)
+	case "status":
+		return migrator.Status()
+	case "baseline":
+		return migrator.Baseline(*version)
+	}
+	return errors.New(migrateUsage)
+}
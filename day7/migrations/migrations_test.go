@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"taskmanager/config"
+	"taskmanager/repository"
+	"testing"
+)
+
+func TestLoadEveryDialect(t *testing.T) {
+	var want []int
+	for _, dialect := range []repository.Dialect{repository.DialectMySQL, repository.DialectPostgres, repository.DialectSQLite} {
+		migrations, err := Load(dialect)
+		if err != nil {
+			t.Fatalf("Load(%s) failed: %v", dialect, err)
+		}
+
+		var versions []int
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s: expected version %d, got %d", dialect, i+1, m.Version)
+			}
+			versions = append(versions, m.Version)
+		}
+
+		if want == nil {
+			want = versions
+		} else if len(want) != len(versions) {
+			t.Errorf("%s has %d migrations, expected %d like the other dialects", dialect, len(versions), len(want))
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	stmts := statements("CREATE TABLE a (id INT);\n\nCREATE INDEX i ON a (id);\n")
+	if len(stmts) != 2 || stmts[1] != "CREATE INDEX i ON a (id)" {
+		t.Errorf("Unexpected statements: %q", stmts)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	all := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+
+	if got := pending(all, 1, 0); len(got) != 2 || got[0].Version != 2 {
+		t.Errorf("Expected versions 2 and 3 pending, got %+v", got)
+	}
+	if got := pending(all, 0, 1); len(got) != 1 || got[0].Version != 1 {
+		t.Errorf("Expected only version 1 with one step, got %+v", got)
+	}
+	if got := applied(all, 2, 5); len(got) != 2 || got[0].Version != 2 || got[1].Version != 1 {
+		t.Errorf("Expected versions 2 then 1 to revert, got %+v", got)
+	}
+}
+
+// TestUpAndDownOnSQLite applies every migration one at a time, then
+// reverts them one at a time, checking that each down script restores the
+// schema its up script started from.
+func TestUpAndDownOnSQLite(t *testing.T) {
+	db, err := config.ConnectDB(repository.DialectSQLite.DriverName(),
+		config.DBConfig{DSN: config.Secret(filepath.Join(t.TempDir(), "migrations.db"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := NewMigrator(db, repository.DialectSQLite, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := []string{sqliteSchema(t, db)}
+	for version := 1; version <= m.Latest(); version++ {
+		if err := m.Up(1); err != nil {
+			t.Fatalf("Up to version %d failed: %v", version, err)
+		}
+		if got, err := m.Version(); err != nil || got != version {
+			t.Fatalf("Expected version %d, got %d, %v", version, got, err)
+		}
+		schemas = append(schemas, sqliteSchema(t, db))
+	}
+	if !strings.Contains(schemas[m.Latest()], "audit_events.request_id") {
+		t.Errorf("Expected the latest schema to have every table, got:\n%s", schemas[m.Latest()])
+	}
+
+	for version := m.Latest() - 1; version >= 0; version-- {
+		if err := m.Down(1); err != nil {
+			t.Fatalf("Down to version %d failed: %v", version, err)
+		}
+		if got, err := m.Version(); err != nil || got != version {
+			t.Fatalf("Expected version %d, got %d, %v", version, got, err)
+		}
+		if got := sqliteSchema(t, db); got != schemas[version] {
+			t.Errorf("Down to version %d left\n%s\nexpected\n%s", version, got, schemas[version])
+		}
+	}
+
+	if err := m.Up(0); err != nil {
+		t.Errorf("Up after reverting everything failed: %v", err)
+	}
+}
+
+// TestBaselineAdoptsExistingSchema adopts a database that already has the
+// users table of the original hand-made schema.
+func TestBaselineAdoptsExistingSchema(t *testing.T) {
+	db, err := config.ConnectDB(repository.DialectSQLite.DriverName(),
+		config.DBConfig{DSN: config.Secret(filepath.Join(t.TempDir(), "baseline.db"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, email TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMigrator(db, repository.DialectSQLite, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(0); err == nil {
+		t.Fatal("Expected migration 1 to fail on the existing users table")
+	}
+	if err := m.Baseline(m.Latest() + 1); err == nil {
+		t.Error("Expected an unknown baseline version to be rejected")
+	}
+	if err := m.Baseline(1); err != nil {
+		t.Fatalf("Baseline failed: %v", err)
+	}
+	if err := m.Baseline(1); err == nil {
+		t.Error("Expected a second baseline to be rejected")
+	}
+	if err := m.Up(0); err != nil {
+		t.Fatalf("Up after the baseline failed: %v", err)
+	}
+	if got, err := m.Version(); err != nil || got != m.Latest() {
+		t.Errorf("Expected version %d, got %d, %v", m.Latest(), got, err)
+	}
+}
+
+// sqliteSchema lists every column and index other than the migrator's own.
+func sqliteSchema(t *testing.T, db *sql.DB) string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT m.name || '.' || p.name || ' ' || p.type FROM sqlite_master m, pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT IN ('schema_migrations', 'sqlite_sequence')
+		UNION ALL
+		SELECT 'index ' || name || ' ON ' || tbl_name FROM sqlite_master WHERE type = 'index' AND name NOT LIKE 'sqlite_autoindex%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
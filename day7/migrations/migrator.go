@@ -0,0 +1,285 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"taskmanager/repository"
+	"time"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    repository.Dialect
+	migrations []Migration
+	out        io.Writer
+	dryRun     bool
+}
+
+func NewMigrator(db *sql.DB, dialect repository.Dialect, out io.Writer, dryRun bool) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = io.Discard
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, out: out, dryRun: dryRun}, nil
+}
+
+// Latest is the version the embedded migrations bring the schema to.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version reports the highest applied migration, or 0 on a fresh database.
+// It fails if schema_migrations has not been created yet.
+func (m *Migrator) Version() (int, error) {
+	return m.VersionContext(context.Background())
+}
+
+func (m *Migrator) VersionContext(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := m.db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+func (m *Migrator) current() (int, error) {
+	if m.dryRun {
+		version, err := m.Version()
+		if err != nil {
+			// A dry run must not create anything, so assume a fresh database.
+			fmt.Fprintf(m.out, "%s;\n", createTable)
+			return 0, nil
+		}
+		return version, nil
+	}
+
+	if _, err := m.db.Exec(createTable); err != nil {
+		return 0, err
+	}
+	return m.Version()
+}
+
+// lockName and lockKey name the migration lock on MySQL, where it is taken
+// with GET_LOCK, and on Postgres, where it is an advisory lock.
+const (
+	lockName    = "taskmanager_schema_migrations"
+	lockKey     = 7_254_110_305
+	lockTimeout = time.Minute
+)
+
+// lock holds a database-wide lock until the returned func is called, so
+// that replicas starting together with auto_migrate apply each migration
+// once: the second waits, then finds nothing pending. SQLite databases
+// belong to a single process and are not locked, nor are dry runs.
+func (m *Migrator) lock() (unlock func(), err error) {
+	if m.dryRun || m.dialect == repository.DialectSQLite {
+		return func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+	// Both locks belong to the session that took them, so one connection
+	// is held for the whole run.
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var release func()
+	switch m.dialect {
+	case repository.DialectMySQL:
+		var got sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&got)
+		if err == nil && got.Int64 != 1 {
+			err = fmt.Errorf("another process held it for %s", lockTimeout)
+		}
+		release = func() { conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName) }
+	default:
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", int64(lockKey))
+		release = func() { conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", int64(lockKey)) }
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+
+	return func() {
+		release()
+		conn.Close()
+	}, nil
+}
+
+// Up applies pending migrations. steps <= 0 applies all of them.
+func (m *Migrator) Up(steps int) error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := m.current()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending(m.migrations, current, steps) {
+		err := m.apply(migration, migration.Up,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// Down reverts applied migrations, newest first. steps <= 0 reverts one.
+func (m *Migrator) Down(steps int) error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := m.current()
+	if err != nil {
+		return err
+	}
+	if steps <= 0 {
+		steps = 1
+	}
+
+	for _, migration := range applied(m.migrations, current, steps) {
+		err := m.apply(migration, migration.Down,
+			"DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// Baseline records the migrations up to version as applied without running
+// them, to adopt a database whose tables were created before migrations
+// existed. The schema must already match that version: the users table of
+// the original hand-made schema is migration 1, and its tasks table needs
+// the columns of migration 2 added before baselining there. It refuses a
+// database that already records migrations.
+func (m *Migrator) Baseline(version int) error {
+	if !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+		return fmt.Errorf("baseline: no migration has version %d", version)
+	}
+
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := m.current()
+	if err != nil {
+		return err
+	}
+	if current != 0 {
+		return fmt.Errorf("baseline: the database already records migrations up to version %d", current)
+	}
+
+	for _, migration := range pending(m.migrations, 0, 0) {
+		if migration.Version > version {
+			break
+		}
+		err := m.apply(migration, "",
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("migration %d_%s baseline: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply runs a migration script and its bookkeeping statement in one
+// transaction. MySQL commits DDL implicitly, so there a failing script can
+// leave earlier statements applied.
+func (m *Migrator) apply(migration Migration, script, record string, args ...any) error {
+	fmt.Fprintf(m.out, "-- %d_%s\n", migration.Version, migration.Name)
+	stmts := statements(script)
+	if m.dryRun {
+		for _, stmt := range stmts {
+			fmt.Fprintf(m.out, "%s;\n", stmt)
+		}
+		return nil
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(m.dialect.Rebind(record), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func pending(migrations []Migration, current, steps int) []Migration {
+	var out []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			out = append(out, migration)
+		}
+	}
+	if steps > 0 && steps < len(out) {
+		out = out[:steps]
+	}
+	return out
+}
+
+func applied(migrations []Migration, current, steps int) []Migration {
+	var out []Migration
+	for i := len(migrations) - 1; i >= 0 && len(out) < steps; i-- {
+		if migrations[i].Version <= current {
+			out = append(out, migrations[i])
+		}
+	}
+	return out
+}
+
+// Status writes every known migration with whether it has been applied.
+func (m *Migrator) Status() error {
+	current, err := m.current()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		state := "pending"
+		if migration.Version <= current {
+			state = "applied"
+		}
+		fmt.Fprintf(m.out, "%04d_%s\t%s\n", migration.Version, migration.Name, state)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+CREATE TABLE tasks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    user_id INT NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'open',
+    priority VARCHAR(16) NOT NULL DEFAULT 'medium',
+    due_date DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    completed_at DATETIME NULL,
+    CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_tasks_user_id ON tasks (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX idx_tasks_user_status ON tasks;
+
+DROP INDEX idx_tasks_user_due_date ON tasks;
@@ -0,0 +1,3 @@
+CREATE INDEX idx_tasks_user_status ON tasks (user_id, status);
+
+CREATE INDEX idx_tasks_user_due_date ON tasks (user_id, due_date);
@@ -0,0 +1,3 @@
+DROP TABLE api_keys;
+
+ALTER TABLE users DROP COLUMN role;
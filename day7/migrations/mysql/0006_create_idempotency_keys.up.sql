@@ -0,0 +1,10 @@
+CREATE TABLE idempotency_keys (
+    id CHAR(64) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    header TEXT NOT NULL,
+    body MEDIUMBLOB NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    INDEX idx_idempotency_keys_expires_at (expires_at)
+);
@@ -0,0 +1,5 @@
+DROP INDEX idx_users_deleted_at ON users;
+ALTER TABLE users DROP COLUMN deleted_at;
+
+DROP INDEX idx_tasks_deleted_at ON tasks;
+ALTER TABLE tasks DROP COLUMN deleted_at;
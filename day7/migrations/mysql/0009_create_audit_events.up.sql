@@ -0,0 +1,13 @@
+CREATE TABLE audit_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor_id INT NOT NULL DEFAULT 0,
+    action VARCHAR(16) NOT NULL,
+    entity_type VARCHAR(16) NOT NULL,
+    entity_id INT NOT NULL,
+    changes MEDIUMTEXT NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    INDEX idx_audit_events_entity (entity_type, entity_id),
+    INDEX idx_audit_events_actor_id (actor_id),
+    INDEX idx_audit_events_created_at (created_at)
+);
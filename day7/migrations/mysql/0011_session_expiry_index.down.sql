@@ -0,0 +1 @@
+DROP INDEX idx_sessions_expires_at ON sessions;
@@ -0,0 +1,14 @@
+CREATE TABLE tasks (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    status VARCHAR(32) NOT NULL DEFAULT 'open',
+    priority VARCHAR(16) NOT NULL DEFAULT 'medium',
+    due_date TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX idx_tasks_user_id ON tasks (user_id);
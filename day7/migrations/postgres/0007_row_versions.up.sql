@@ -0,0 +1,3 @@
+ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+DROP INDEX idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+
+DROP INDEX idx_tasks_deleted_at;
+ALTER TABLE tasks DROP COLUMN deleted_at;
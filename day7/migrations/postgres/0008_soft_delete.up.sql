@@ -0,0 +1,5 @@
+ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+CREATE INDEX idx_tasks_deleted_at ON tasks (deleted_at);
+
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1 @@
+CREATE INDEX idx_sessions_expires_at ON sessions (expires_at);
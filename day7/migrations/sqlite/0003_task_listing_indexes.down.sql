@@ -0,0 +1,3 @@
+DROP INDEX idx_tasks_user_status;
+
+DROP INDEX idx_tasks_user_due_date;
@@ -0,0 +1,4 @@
+DROP TABLE sessions;
+
+DROP INDEX uq_users_email;
+ALTER TABLE users DROP COLUMN password_hash;
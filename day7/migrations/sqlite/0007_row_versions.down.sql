@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN version;
+
+ALTER TABLE tasks DROP COLUMN version;
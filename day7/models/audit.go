@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditAction is what a change did to an entity.
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+)
+
+func (a AuditAction) Valid() bool {
+	switch a {
+	case AuditCreate, AuditUpdate, AuditDelete, AuditRestore:
+		return true
+	}
+	return false
+}
+
+// The entity types an AuditEvent can refer to.
+const (
+	AuditEntityTask = "task"
+	AuditEntityUser = "user"
+)
+
+// AuditEvent records one change to a task or user. Events are only ever
+// appended, and outlive the entities they refer to.
+type AuditEvent struct {
+	ID int `json:"id"`
+	// ActorID is the user who made the change, or 0 when no user did, as
+	// for a self-registration or the create-admin command.
+	ActorID    int         `json:"actor_id,omitempty"`
+	Action     AuditAction `json:"action"`
+	EntityType string      `json:"entity_type"`
+	EntityID   int         `json:"entity_id"`
+	// Changes holds every field that changed, by its JSON name.
+	Changes   map[string]AuditChange `json:"changes"`
+	RequestID string                 `json:"request_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditChange is a field's JSON value before and after a change. Before is
+// absent for fields that were unset and After for fields that were
+// cleared. Secrets such as passwords are recorded with neither.
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditQuery filters the audit log. Zero fields match every event. Events
+// are listed newest first; Before is the id of the last event of the
+// previous page.
+type AuditQuery struct {
+	ActorID    int
+	Action     AuditAction
+	EntityType string
+	EntityID   int
+	RequestID  string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Cursor     string
+	Before     int
+}
+
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyRecord is the response kept for an Idempotency-Key so that a
+// retried request gets the same answer instead of running twice. Key is a
+// hash that scopes the client's key to its route and caller; RequestHash
+// is a hash of the request body. Status is zero while the first request is
+// still being handled. Owner identifies the reservation, so that a request
+// that outlives its lease cannot complete or release the reservation of the
+// retry that took it over.
+type IdempotencyRecord struct {
+	Key         string
+	Owner       string
+	RequestHash string
+	Status      int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
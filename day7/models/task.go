@@ -1,8 +1,83 @@
 package models
 
+import "time"
+
+type TaskStatus string
+
+const (
+	StatusOpen       TaskStatus = "open"
+	StatusInProgress TaskStatus = "in_progress"
+	StatusBlocked    TaskStatus = "blocked"
+	StatusDone       TaskStatus = "done"
+	StatusArchived   TaskStatus = "archived"
+)
+
+func (s TaskStatus) Valid() bool {
+	switch s {
+	case StatusOpen, StatusInProgress, StatusBlocked, StatusDone, StatusArchived:
+		return true
+	}
+	return false
+}
+
+type TaskPriority string
+
+const (
+	PriorityLow    TaskPriority = "low"
+	PriorityMedium TaskPriority = "medium"
+	PriorityHigh   TaskPriority = "high"
+	PriorityUrgent TaskPriority = "urgent"
+)
+
+func (p TaskPriority) Valid() bool {
+	switch p {
+	case PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+		return true
+	}
+	return false
+}
+
+// Rank orders priorities from least to most pressing.
+func (p TaskPriority) Rank() int {
+	switch p {
+	case PriorityLow:
+		return 1
+	case PriorityMedium:
+		return 2
+	case PriorityHigh:
+		return 3
+	case PriorityUrgent:
+		return 4
+	}
+	return 0
+}
+
 type Task struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	UserId      int    `json:"user_id"`
+	ID          int          `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	UserId      int          `json:"user_id"`
+	Status      TaskStatus   `json:"status"`
+	Priority    TaskPriority `json:"priority"`
+	DueDate     *time.Time   `json:"due_date,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	// Version counts the writes to the task. It is the task's ETag, so a
+	// client can make an update conditional on nobody having changed the
+	// task since it read it.
+	Version int `json:"version"`
+	// DeletedAt is set while the task is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type TaskPatch struct {
+	Title       *string       `json:"title"`
+	Description *string       `json:"description"`
+	Priority    *TaskPriority `json:"priority"`
+	DueDate     *time.Time    `json:"due_date"`
+}
+
+type TaskTransition struct {
+	Status TaskStatus `json:"status"`
 }
@@ -0,0 +1,41 @@
+package models
+
+type BatchMode string
+
+const (
+	// BatchAtomic applies every item or none of them.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort applies the items that succeed and reports the rest.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+func (m BatchMode) Valid() bool {
+	return m == BatchAtomic || m == BatchBestEffort
+}
+
+type BatchAction string
+
+const (
+	BatchCreate BatchAction = "create"
+	BatchUpdate BatchAction = "update"
+	BatchDelete BatchAction = "delete"
+)
+
+type TaskBatch struct {
+	Mode  BatchMode       `json:"mode"`
+	Items []TaskBatchItem `json:"items"`
+}
+
+// TaskBatchItem is one write in a batch. Creates carry Task; updates name
+// the task by ID and apply Patch and, optionally, a transition to Status;
+// deletes only need ID. Updates and deletes must give the Version they
+// expect and only apply to the task at that version, like a single request
+// with If-Match.
+type TaskBatchItem struct {
+	Action  BatchAction `json:"action"`
+	ID      int         `json:"id,omitempty"`
+	Version int         `json:"version,omitempty"`
+	Task    *Task       `json:"task,omitempty"`
+	Patch   *TaskPatch  `json:"patch,omitempty"`
+	Status  TaskStatus  `json:"status,omitempty"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+const (
+	SortByID        = "id"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	SortByDueDate   = "due_date"
+	SortByPriority  = "priority"
+	SortByTitle     = "title"
+)
+
+type TaskSort struct {
+	Field string
+	Desc  bool
+}
+
+func (s TaskSort) Valid() bool {
+	switch s.Field {
+	case SortByID, SortByCreatedAt, SortByUpdatedAt, SortByDueDate, SortByPriority, SortByTitle:
+		return true
+	}
+	return false
+}
+
+func (s TaskSort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// TaskCursor marks the last task of a page: the value of the sort field
+// and the id used to break ties.
+type TaskCursor struct {
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+type TaskQuery struct {
+	UserID     int
+	Statuses   []TaskStatus
+	Priorities []TaskPriority
+	DueBefore  *time.Time
+	DueAfter   *time.Time
+	Search     string
+	Sort       TaskSort
+	Limit      int
+	Cursor     string
+	After      *TaskCursor
+}
+
+type TaskPage struct {
+	Tasks      []Task `json:"tasks"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
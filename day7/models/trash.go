@@ -0,0 +1,8 @@
+package models
+
+// Trash lists deleted records that can still be restored. Users are only
+// listed for admins.
+type Trash struct {
+	Tasks []Task `json:"tasks"`
+	Users []User `json:"users,omitempty"`
+}
@@ -1,7 +1,62 @@
 package models
 
+import "time"
+
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+	// Version counts the writes to the user and is served as its ETag.
+	Version int `json:"version"`
+	// DeletedAt is set while the user is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// APIKey is a long-lived credential a user exchanges for access tokens.
+// Only the hash of the key is ever stored.
+type APIKey struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskDisposition says what happens to a user's tasks when the user is
+// deleted.
+type TaskDisposition string
+
+const (
+	TasksReject   TaskDisposition = "reject"
+	TasksCascade  TaskDisposition = "cascade"
+	TasksReassign TaskDisposition = "reassign"
+)
+
+// Credentials identify a user at login: either an email and password or an
+// API key.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	APIKey   string `json:"api_key"`
+}
+
+// Session is a server-side login. Access tokens name the session they were
+// issued for, so revoking it ends every token at once.
+type Session struct {
+	ID        string     `json:"id"`
+	UserID    int        `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type AccessToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
@@ -0,0 +1,116 @@
+// Package openapi embeds the API's OpenAPI 3 document and the docs page that
+// renders it, and validates request bodies against the document's schemas.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// Document is the parsed API description.
+type Document struct {
+	raw        []byte
+	operations map[string]*Operation
+}
+
+// Operation is one method on one path, keyed by its ServeMux pattern such
+// as "PUT /tasks/{id}".
+type Operation struct {
+	ID string
+	// Body is the JSON request body schema, or nil when the operation takes
+	// no body.
+	Body *Schema
+}
+
+type rawDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type rawOperation struct {
+	OperationID string `json:"operationId"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+var methods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// Load parses the embedded document and resolves its schema references.
+func Load() (*Document, error) {
+	var raw rawDocument
+	if err := json.Unmarshal(document, &raw); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	resolving := map[*Schema]bool{}
+	for name, s := range raw.Components.Schemas {
+		if err := s.resolve(raw.Components.Schemas, resolving); err != nil {
+			return nil, fmt.Errorf("openapi: schema %s: %w", name, err)
+		}
+	}
+
+	doc := &Document{raw: document, operations: map[string]*Operation{}}
+	for path, item := range raw.Paths {
+		for _, method := range methods {
+			data, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op rawOperation
+			if err := json.Unmarshal(data, &op); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", method, path, err)
+			}
+
+			pattern := strings.ToUpper(method) + " " + path
+			parsed := &Operation{ID: op.OperationID}
+			if op.RequestBody != nil {
+				if media, ok := op.RequestBody.Content["application/json"]; ok && media.Schema != nil {
+					if err := media.Schema.resolve(raw.Components.Schemas, resolving); err != nil {
+						return nil, fmt.Errorf("openapi: %s: %w", pattern, err)
+					}
+					parsed.Body = media.Schema
+				}
+			}
+			doc.operations[pattern] = parsed
+		}
+	}
+	return doc, nil
+}
+
+// JSON returns the document as served at /openapi.json.
+func (d *Document) JSON() []byte {
+	return d.raw
+}
+
+// DocsPage returns the HTML page served at /docs.
+func (d *Document) DocsPage() []byte {
+	return docsPage
+}
+
+// Operation looks up the operation for a ServeMux pattern.
+func (d *Document) Operation(pattern string) (*Operation, bool) {
+	op, ok := d.operations[pattern]
+	return op, ok
+}
+
+// Patterns lists the ServeMux pattern of every operation in the document.
+func (d *Document) Patterns() []string {
+	patterns := make([]string, 0, len(d.operations))
+	for p := range d.operations {
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
@@ -0,0 +1,74 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLoadResolvesOperations(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	op, ok := doc.Operation("POST /tasks")
+	if !ok || op.ID != "createTask" || op.Body == nil || op.Body.Properties["status"].Enum == nil {
+		t.Fatalf("Expected POST /tasks with a resolved body schema, got %+v", op)
+	}
+	if op, ok := doc.Operation("GET /tasks/{id}"); !ok || op.Body != nil {
+		t.Errorf("Expected GET /tasks/{id} without a body, got %+v", op)
+	}
+	if _, ok := doc.Operation("GET /nowhere"); ok {
+		t.Error("Expected no operation for an undocumented pattern")
+	}
+	if !json.Valid(doc.JSON()) {
+		t.Error("Expected the served document to be valid JSON")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var s Schema
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["items"],
+		"properties": {
+			"items": {"type": "array", "items": {"$ref": "#/components/schemas/Item"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string", "maxLength": 3}}
+		}
+	}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := map[string]*Schema{}
+	json.Unmarshal([]byte(`{"Item": {"type": "object", "required": ["n"], "properties": {"n": {"type": "integer", "minimum": 1, "maximum": 10}}}}`), &components)
+	if err := s.resolve(components, map[*Schema]bool{}); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	violations, err := s.Validate([]byte(`{"items":[{"n":1},{"n":1.5},{},{"n":0},{"n":11}],"labels":{"a":"x","b":2,"c":"ünïc","d":"äöü"},"extra":true}`))
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	want := []Violation{
+		{"extra", "is not a known field"},
+		{"items[1].n", "must be an integer"},
+		{"items[2].n", "is required"},
+		{"items[3].n", "must be at least 1"},
+		{"items[4].n", "must be at most 10"},
+		{"labels.b", "must be a string"},
+		{"labels.c", "must be at most 3 characters"},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, violations)
+	}
+	for i := range want {
+		if violations[i] != want[i] {
+			t.Errorf("Violation %d: expected %v, got %v", i, want[i], violations[i])
+		}
+	}
+
+	if _, err := s.Validate([]byte(`{"items":[]`)); err != ErrInvalidJSON {
+		t.Errorf("Expected ErrInvalidJSON, got %v", err)
+	}
+}
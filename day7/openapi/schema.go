@@ -0,0 +1,237 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema is the subset of the OpenAPI 3.0 schema object the validator
+// understands: types, enums, numeric bounds, maximum string lengths,
+// required and unknown properties, nullable, arrays, allOf and the
+// date-time format. Keywords that only document a value, such as
+// description or example, are ignored.
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Nullable   bool               `json:"nullable"`
+	ReadOnly   bool               `json:"readOnly"`
+	Enum       []any              `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	MaxLength  *int               `json:"maxLength"`
+	Required   []string           `json:"required"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+	AllOf      []*Schema          `json:"allOf"`
+
+	// AdditionalProperties is true, false or a schema for the values.
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+
+	closed bool
+	values *Schema
+}
+
+// Violation is one way a value fails its schema. Field is a path such as
+// "title" or "tasks[2].priority"; it is empty for the body as a whole.
+type Violation struct {
+	Field   string
+	Message string
+}
+
+// ErrInvalidJSON is returned by Validate for bodies that are not JSON.
+var ErrInvalidJSON = errors.New("request body is not valid JSON")
+
+// resolve replaces $ref schemas by the component they name, in place, and
+// decodes additionalProperties.
+func (s *Schema) resolve(components map[string]*Schema, resolving map[*Schema]bool) error {
+	if s == nil || resolving[s] {
+		return nil
+	}
+	resolving[s] = true
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		target := components[name]
+		if !ok || target == nil {
+			return fmt.Errorf("unresolvable $ref %q", s.Ref)
+		}
+		if err := target.resolve(components, resolving); err != nil {
+			return err
+		}
+		*s = *target
+		return nil
+	}
+
+	switch raw := bytes.TrimSpace(s.AdditionalProperties); {
+	case len(raw) == 0, string(raw) == "true":
+	case string(raw) == "false":
+		s.closed = true
+	default:
+		s.values = &Schema{}
+		if err := json.Unmarshal(raw, s.values); err != nil {
+			return fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+
+	for _, child := range s.Properties {
+		if err := child.resolve(components, resolving); err != nil {
+			return err
+		}
+	}
+	for _, child := range append([]*Schema{s.Items, s.values}, s.AllOf...) {
+		if err := child.resolve(components, resolving); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a JSON document against s. It returns ErrInvalidJSON if
+// data does not parse, and otherwise every violation found, sorted by field.
+func (s *Schema) Validate(data []byte) ([]Violation, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, ErrInvalidJSON
+	}
+	if err := dec.Decode(&value); err != io.EOF {
+		return nil, ErrInvalidJSON
+	}
+
+	var violations []Violation
+	s.validate("", value, &violations)
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+	return violations, nil
+}
+
+func (s *Schema) validate(field string, value any, out *[]Violation) {
+	report := func(format string, args ...any) {
+		*out = append(*out, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil && s.Nullable {
+		return
+	}
+	for _, sub := range s.AllOf {
+		sub.validate(field, value, out)
+	}
+	if value == nil {
+		if s.Type != "" {
+			report("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			report("must be an object")
+			return
+		}
+		s.validateObject(field, obj, out)
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			report("must be an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, out)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			report("must be a string")
+			return
+		}
+		if s.MaxLength != nil && utf8.RuneCountInString(str) > *s.MaxLength {
+			report("must be at most %d characters", *s.MaxLength)
+			return
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				report("must be an RFC 3339 timestamp")
+				return
+			}
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			report("must be an integer")
+			return
+		}
+		s.validateBounds(n, report)
+	case "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			report("must be a number")
+			return
+		}
+		s.validateBounds(n, report)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("must be a boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		report("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+func (s *Schema) validateBounds(n json.Number, report func(format string, args ...any)) {
+	f, err := n.Float64()
+	if err != nil {
+		return
+	}
+	if s.Minimum != nil && f < *s.Minimum {
+		report("must be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		report("must be at most %v", *s.Maximum)
+	}
+}
+
+func (s *Schema) validateObject(field string, obj map[string]any, out *[]Violation) {
+	join := func(name string) string {
+		if field == "" {
+			return name
+		}
+		return field + "." + name
+	}
+
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*out = append(*out, Violation{Field: join(name), Message: "is required"})
+		}
+	}
+	for name, value := range obj {
+		prop, ok := s.Properties[name]
+		switch {
+		case ok:
+			prop.validate(join(name), value, out)
+		case s.values != nil:
+			s.values.validate(join(name), value, out)
+		case s.closed:
+			*out = append(*out, Violation{Field: join(name), Message: "is not a known field"})
+		}
+	}
+}
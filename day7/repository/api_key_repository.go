@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"taskmanager/models"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+type apiKeyRepository struct {
+	sqlStore
+}
+
+func NewAPIKeyRepository(db *sql.DB, dialect Dialect, opts Options) APIKeyRepository {
+	return &apiKeyRepository{sqlStore: newSQLStore(db, dialect, opts)}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := "INSERT INTO api_keys (user_id, key_hash, created_at) VALUES (?, ?, ?)"
+	id, err := r.insert(ctx, query, key.UserID, key.Hash, key.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	key.ID = id
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := "SELECT id, user_id, key_hash, created_at FROM api_keys WHERE key_hash = ?"
+
+	var key models.APIKey
+	err := r.queryRow(ctx, query, hash).Scan(&key.ID, &key.UserID, &key.Hash, &key.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"taskmanager/models"
+)
+
+// AuditRepository is append-only: events are never changed or removed.
+type AuditRepository interface {
+	// RecordAuditEvent must run in the transaction that makes the change
+	// it records.
+	RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	// ListAuditEvents returns up to q.Limit events matching q, newest
+	// first, and the id to pass as q.Before for the next page, or 0 when
+	// there are no more.
+	ListAuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, int, error)
+}
+
+type auditRepository struct {
+	sqlStore
+}
+
+func NewAuditRepository(db *sql.DB, dialect Dialect, opts Options) AuditRepository {
+	return &auditRepository{sqlStore: newSQLStore(db, dialect, opts)}
+}
+
+func (r *auditRepository) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_events (actor_id, action, entity_type, entity_id, changes, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	id, err := r.insert(ctx, query, event.ActorID, event.Action, event.EntityType, event.EntityID,
+		string(changes), event.RequestID, event.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	event.ID = id
+	return nil
+}
+
+func (r *auditRepository) ListAuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, int, error) {
+	var where []string
+	var args []any
+	filter := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if q.ActorID != 0 {
+		filter("actor_id = ?", q.ActorID)
+	}
+	if q.Action != "" {
+		filter("action = ?", q.Action)
+	}
+	if q.EntityType != "" {
+		filter("entity_type = ?", q.EntityType)
+	}
+	if q.EntityID != 0 {
+		filter("entity_id = ?", q.EntityID)
+	}
+	if q.RequestID != "" {
+		filter("request_id = ?", q.RequestID)
+	}
+	if q.Since != nil {
+		filter("created_at >= ?", *q.Since)
+	}
+	if q.Until != nil {
+		filter("created_at < ?", *q.Until)
+	}
+	if q.Before != 0 {
+		filter("id < ?", q.Before)
+	}
+
+	query := "SELECT id, actor_id, action, entity_type, entity_id, changes, request_id, created_at FROM audit_events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var changes string
+		err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.EntityType, &event.EntityID,
+			&changes, &event.RequestID, &event.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(changes), &event.Changes); err != nil {
+			return nil, 0, fmt.Errorf("audit event %d: decoding changes: %w", event.ID, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	events, next := auditPageOf(events, q.Limit)
+	return events, next, nil
+}
+
+// auditPageOf trims a result fetched with limit+1 rows, like pageOf.
+func auditPageOf(events []models.AuditEvent, limit int) ([]models.AuditEvent, int) {
+	if limit <= 0 || len(events) <= limit {
+		return events, 0
+	}
+	events = events[:limit]
+	return events, events[limit-1].ID
+}
@@ -0,0 +1,759 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+type backendFactory func(t *testing.T) *Repositories
+
+// runConformance is the behaviour every storage backend must share.
+func runConformance(t *testing.T, newRepos backendFactory) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	t.Run("UserRoundTrip", func(t *testing.T) {
+		repos := newRepos(t)
+
+		hash, err := auth.HashPassword("correct horse battery staple")
+		if err != nil {
+			t.Fatalf("HashPassword failed: %v", err)
+		}
+		user := models.User{Name: "Aman", Email: "aman@example.com", Role: "admin", PasswordHash: hash}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		if user.ID == 0 || user.Version != 1 {
+			t.Fatalf("Expected CreateUser to assign an id and version 1, got %+v", user)
+		}
+
+		got, err := repos.Users.GetUserByID(ctx, user.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetUserByID returned %v, %v", got, err)
+		}
+		if *got != user {
+			t.Errorf("Expected %+v, got %+v", user, *got)
+		}
+
+		byEmail, err := repos.Users.GetUserByEmail(ctx, user.Email)
+		if err != nil || byEmail == nil || byEmail.ID != user.ID {
+			t.Errorf("GetUserByEmail returned %+v, %v", byEmail, err)
+		}
+		if got, err := repos.Users.GetUserByEmail(ctx, "nobody@example.com"); err != nil || got != nil {
+			t.Errorf("Expected nil, nil for an unknown email, got %+v, %v", got, err)
+		}
+
+		newHash, err := auth.HashPassword("battery staple correct horse")
+		if err != nil {
+			t.Fatalf("HashPassword failed: %v", err)
+		}
+		if err := repos.Users.UpdatePasswordHash(ctx, user.ID, user.Version, newHash); err != nil {
+			t.Fatalf("UpdatePasswordHash failed: %v", err)
+		}
+		if got, _ := repos.Users.GetUserByID(ctx, user.ID); got == nil || got.PasswordHash != newHash || got.Version != 2 {
+			t.Errorf("Expected the new hash to be stored at version 2, got %+v", got)
+		}
+		if err := repos.Users.UpdatePasswordHash(ctx, user.ID, user.Version, "x"); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict for a stale version, got %v", err)
+		}
+		if err := repos.Users.UpdatePasswordHash(ctx, 999999, 1, "x"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a missing user, got %v", err)
+		}
+
+		duplicate := models.User{Name: "Copy", Email: user.Email, Role: "user"}
+		if err := repos.Users.CreateUser(ctx, &duplicate); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("Expected ErrDuplicate for a reused email, got %v", err)
+		}
+	})
+
+	t.Run("MissingUser", func(t *testing.T) {
+		repos := newRepos(t)
+
+		got, err := repos.Users.GetUserByID(ctx, 999999)
+		if err != nil || got != nil {
+			t.Errorf("Expected nil, nil for a missing user, got %v, %v", got, err)
+		}
+	})
+
+	t.Run("TaskLifecycle", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Owner", Email: "owner@example.com"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		task := models.Task{
+			Title:     "Write tests",
+			UserId:    user.ID,
+			Status:    models.StatusOpen,
+			Priority:  models.PriorityHigh,
+			DueDate:   &now,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := repos.Tasks.CreateTask(ctx, &task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+
+		task.Status = models.StatusDone
+		task.CompletedAt = &now
+		if err := repos.Tasks.UpdateTask(ctx, &task); err != nil || task.Version != 2 {
+			t.Fatalf("UpdateTask returned %v at version %d", err, task.Version)
+		}
+		stale := task
+		stale.Version = 1
+		if err := repos.Tasks.UpdateTask(ctx, &stale); !errors.Is(err, ErrVersionConflict) || stale.Version != 1 {
+			t.Errorf("Expected ErrVersionConflict for a stale version, got %v", err)
+		}
+		missing := models.Task{ID: 999999, Title: "Missing", UserId: user.ID, Status: models.StatusOpen, Priority: models.PriorityLow, Version: 1}
+		if err := repos.Tasks.UpdateTask(ctx, &missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound updating a missing task, got %v", err)
+		}
+
+		got, err := repos.Tasks.GetTaskByID(ctx, task.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetTaskByID returned %v, %v", got, err)
+		}
+		if got.Status != models.StatusDone || got.Priority != models.PriorityHigh || got.CompletedAt == nil {
+			t.Errorf("Unexpected task after update: %+v", got)
+		}
+		if got.DueDate == nil || !got.DueDate.Equal(now) {
+			t.Errorf("Expected due date %v, got %v", now, got.DueDate)
+		}
+		if got.Version != 2 {
+			t.Errorf("Expected version 2, got %d", got.Version)
+		}
+
+		second := models.Task{Title: "Second", UserId: user.ID, Status: models.StatusOpen,
+			Priority: models.PriorityLow, CreatedAt: now, UpdatedAt: now}
+		if err := repos.Tasks.CreateTask(ctx, &second); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+
+		tasks, err := repos.Tasks.GetTasksByUserID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetTasksByUserID failed: %v", err)
+		}
+		if len(tasks) != 2 || tasks[0].ID != task.ID || tasks[1].ID != second.ID {
+			t.Errorf("Expected both tasks ordered by id, got %+v", tasks)
+		}
+
+		if err := repos.Tasks.DeleteTask(ctx, task.ID, 1, now); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict deleting a stale version, got %v", err)
+		}
+		if err := repos.Tasks.DeleteTask(ctx, task.ID, task.Version, now); err != nil {
+			t.Fatalf("DeleteTask failed: %v", err)
+		}
+		if got, _ := repos.Tasks.GetTaskByID(ctx, task.ID); got != nil {
+			t.Errorf("Expected task to be gone, got %+v", got)
+		}
+		if err := repos.Tasks.DeleteTask(ctx, task.ID, task.Version, now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+		}
+	})
+
+	t.Run("TaskBatch", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Importer", Email: "importer@example.com"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		newTask := func(title string) *models.Task {
+			return &models.Task{Title: title, UserId: user.ID, Status: models.StatusOpen,
+				Priority: models.PriorityMedium, CreatedAt: now, UpdatedAt: now}
+		}
+
+		imported := []*models.Task{newTask("Row 1"), newTask("Row 2"), newTask("Row 3")}
+		if err := repos.Tasks.CreateTasks(ctx, imported); err != nil {
+			t.Fatalf("CreateTasks failed: %v", err)
+		}
+		imported[1].DeletedAt = &now
+		for _, task := range imported {
+			got, err := repos.Tasks.GetTaskByID(ctx, task.ID)
+			if err != nil || got == nil || got.Title != task.Title {
+				t.Errorf("Expected %q stored under id %d, got %+v, %v", task.Title, task.ID, got, err)
+			}
+		}
+
+		renamed := *imported[0]
+		renamed.Title = "Row 1 (renamed)"
+		ops := []TaskOp{
+			{Action: models.BatchCreate, Task: newTask("Batch A")},
+			{Action: models.BatchCreate, Task: newTask("Batch B")},
+			{Action: models.BatchUpdate, Task: &renamed},
+			{Action: models.BatchDelete, Task: &models.Task{ID: 999999, DeletedAt: &now}},
+			{Action: models.BatchDelete, Task: imported[1]},
+		}
+
+		errs, err := repos.Tasks.ApplyBatch(ctx, ops, true)
+		if err != nil {
+			t.Fatalf("ApplyBatch failed: %v", err)
+		}
+		if !errors.Is(errs[3], ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for the missing task, got %v", errs)
+		}
+		if count, _ := repos.Tasks.CountTasksByUserID(ctx, user.ID); count != 3 {
+			t.Errorf("Expected the atomic batch to leave 3 tasks, got %d", count)
+		}
+		if got, _ := repos.Tasks.GetTaskByID(ctx, imported[0].ID); got == nil || got.Title != "Row 1" {
+			t.Errorf("Expected the update to be rolled back, got %+v", got)
+		}
+
+		// The rolled back update still bumped the caller's copy.
+		ops[0].Task, ops[1].Task = newTask("Batch A"), newTask("Batch B")
+		renamed.Version = imported[0].Version
+		errs, err = repos.Tasks.ApplyBatch(ctx, ops, false)
+		if err != nil {
+			t.Fatalf("ApplyBatch failed: %v", err)
+		}
+		for i, err := range errs {
+			if (i == 3) != (err != nil) {
+				t.Errorf("Unexpected error for op %d: %v", i, err)
+			}
+		}
+		tasks, err := repos.Tasks.GetTasksByUserID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetTasksByUserID failed: %v", err)
+		}
+		var titles []string
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		if got := strings.Join(titles, ","); got != "Row 1 (renamed),Row 3,Batch A,Batch B" {
+			t.Errorf("Unexpected tasks after a best-effort batch: %s", got)
+		}
+	})
+
+	t.Run("CreateTasksKeepsRowOrder", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Bulk", Email: "bulk@example.com"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		// Two chunks, so the second statement starts after the first's ids.
+		now := time.Now().UTC().Truncate(time.Second)
+		tasks := make([]*models.Task, insertChunk+20)
+		for i := range tasks {
+			tasks[i] = &models.Task{Title: fmt.Sprintf("Row %d", i), UserId: user.ID, Status: models.StatusOpen,
+				Priority: models.PriorityMedium, CreatedAt: now, UpdatedAt: now}
+		}
+		if err := repos.Tasks.CreateTasks(ctx, tasks); err != nil {
+			t.Fatalf("CreateTasks failed: %v", err)
+		}
+
+		stored, err := repos.Tasks.GetTasksByUserID(ctx, user.ID)
+		if err != nil || len(stored) != len(tasks) {
+			t.Fatalf("Expected %d tasks, got %d, %v", len(tasks), len(stored), err)
+		}
+		for i, task := range stored {
+			if task.ID != tasks[i].ID || task.Title != tasks[i].Title {
+				t.Fatalf("Expected %q under id %d, got %q under %d", tasks[i].Title, tasks[i].ID, task.Title, task.ID)
+			}
+		}
+	})
+
+	t.Run("ListTasks", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Lister", Email: "lister@example.com"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		fixtures := []struct {
+			title    string
+			status   models.TaskStatus
+			priority models.TaskPriority
+			due      int
+		}{
+			{"Plan sprint", models.StatusOpen, models.PriorityHigh, 3},
+			{"Fix 100% CPU bug", models.StatusInProgress, models.PriorityUrgent, 1},
+			{"Write plan_b", models.StatusOpen, models.PriorityLow, 0},
+			{"Review plan", models.StatusDone, models.PriorityMedium, 2},
+		}
+		for _, f := range fixtures {
+			task := models.Task{Title: f.title, UserId: user.ID, Status: f.status, Priority: f.priority,
+				CreatedAt: base, UpdatedAt: base}
+			if f.due > 0 {
+				due := base.AddDate(0, 0, f.due)
+				task.DueDate = &due
+			}
+			if err := repos.Tasks.CreateTask(ctx, &task); err != nil {
+				t.Fatalf("CreateTask failed: %v", err)
+			}
+		}
+
+		titles := func(q models.TaskQuery) []string {
+			t.Helper()
+			var out []string
+			for {
+				tasks, next, err := repos.Tasks.ListTasks(ctx, q)
+				if err != nil {
+					t.Fatalf("ListTasks failed: %v", err)
+				}
+				for _, task := range tasks {
+					out = append(out, task.Title)
+				}
+				if next == nil {
+					return out
+				}
+				q.After = next
+			}
+		}
+
+		tests := []struct {
+			name string
+			q    models.TaskQuery
+			want string
+		}{
+			{"by id", models.TaskQuery{Sort: models.TaskSort{Field: models.SortByID}, Limit: 3},
+				"Plan sprint,Fix 100% CPU bug,Write plan_b,Review plan"},
+			{"priority desc", models.TaskQuery{Sort: models.TaskSort{Field: models.SortByPriority, Desc: true}, Limit: 1},
+				"Fix 100% CPU bug,Plan sprint,Review plan,Write plan_b"},
+			{"due date puts undated last", models.TaskQuery{Sort: models.TaskSort{Field: models.SortByDueDate}, Limit: 2},
+				"Fix 100% CPU bug,Review plan,Plan sprint,Write plan_b"},
+			{"status filter", models.TaskQuery{Statuses: []models.TaskStatus{models.StatusOpen},
+				Sort: models.TaskSort{Field: models.SortByTitle}}, "Plan sprint,Write plan_b"},
+			{"due window", models.TaskQuery{DueAfter: timePtr(base.AddDate(0, 0, 1)), DueBefore: timePtr(base.AddDate(0, 0, 3)),
+				Sort: models.TaskSort{Field: models.SortByID}}, "Review plan"},
+			{"search is literal", models.TaskQuery{Search: "100%", Sort: models.TaskSort{Field: models.SortByID}},
+				"Fix 100% CPU bug"},
+			{"search ignores case and underscores", models.TaskQuery{Search: "PLAN_", Sort: models.TaskSort{Field: models.SortByID}},
+				"Write plan_b"},
+		}
+
+		for _, tt := range tests {
+			tt.q.UserID = user.ID
+			if got := strings.Join(titles(tt.q), ","); got != tt.want {
+				t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("UserTaskOwnership", func(t *testing.T) {
+		repos := newRepos(t)
+		var users [2]models.User
+		for i := range users {
+			users[i] = models.User{Name: "Member", Email: fmt.Sprintf("member%d@example.com", i)}
+			if err := repos.Users.CreateUser(ctx, &users[i]); err != nil {
+				t.Fatalf("CreateUser failed: %v", err)
+			}
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		for range 3 {
+			task := models.Task{Title: "Owned", UserId: users[0].ID, Status: models.StatusOpen,
+				Priority: models.PriorityLow, CreatedAt: now, UpdatedAt: now}
+			if err := repos.Tasks.CreateTask(ctx, &task); err != nil {
+				t.Fatalf("CreateTask failed: %v", err)
+			}
+		}
+
+		if moved, err := repos.Tasks.ReassignTasks(ctx, users[0].ID, users[1].ID); err != nil || moved != 3 {
+			t.Fatalf("ReassignTasks returned %d, %v", moved, err)
+		}
+		if count, err := repos.Tasks.CountTasksByUserID(ctx, users[0].ID); err != nil || count != 0 {
+			t.Errorf("Expected no tasks left for the first user, got %d, %v", count, err)
+		}
+		if err := repos.Users.DeleteUser(ctx, users[0].ID, users[0].Version, now); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		if err := repos.Users.DeleteUser(ctx, users[0].ID, users[0].Version, now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+		}
+
+		if deleted, err := repos.Tasks.DeleteTasksByUserID(ctx, users[1].ID, now); err != nil || deleted != 3 {
+			t.Errorf("DeleteTasksByUserID returned %d, %v", deleted, err)
+		}
+		if trashed, err := repos.Tasks.ListDeletedTasks(ctx, users[1].ID); err != nil || len(trashed) != 3 {
+			t.Errorf("Expected the 3 tasks in the trash, got %d, %v", len(trashed), err)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Tidy", Email: "tidy@example.com"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		var tasks [2]models.Task
+		for i := range tasks {
+			tasks[i] = models.Task{Title: fmt.Sprintf("Task %d", i), UserId: user.ID, Status: models.StatusOpen,
+				Priority: models.PriorityLow, CreatedAt: base, UpdatedAt: base}
+			if err := repos.Tasks.CreateTask(ctx, &tasks[i]); err != nil {
+				t.Fatalf("CreateTask failed: %v", err)
+			}
+			if err := repos.Tasks.DeleteTask(ctx, tasks[i].ID, 1, base.Add(time.Duration(i)*time.Hour)); err != nil {
+				t.Fatalf("DeleteTask failed: %v", err)
+			}
+		}
+
+		if count, _ := repos.Tasks.CountTasksByUserID(ctx, user.ID); count != 0 {
+			t.Errorf("Expected deleted tasks not to be counted, got %d", count)
+		}
+		trashed, err := repos.Tasks.ListDeletedTasks(ctx, user.ID)
+		if err != nil || len(trashed) != 2 || trashed[0].ID != tasks[1].ID || trashed[0].DeletedAt == nil || trashed[0].Version != 2 {
+			t.Fatalf("Expected the latest deletion first, got %+v, %v", trashed, err)
+		}
+		tasks[0].Version = 2
+		if err := repos.Tasks.UpdateTask(ctx, &tasks[0]); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound updating a deleted task, got %v", err)
+		}
+
+		if err := repos.Tasks.RestoreTask(ctx, tasks[1].ID, 1); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict restoring a stale version, got %v", err)
+		}
+		if err := repos.Tasks.RestoreTask(ctx, tasks[1].ID, 2); err != nil {
+			t.Fatalf("RestoreTask failed: %v", err)
+		}
+		if got, _ := repos.Tasks.GetTaskByID(ctx, tasks[1].ID); got == nil || got.DeletedAt != nil || got.Version != 3 {
+			t.Errorf("Expected the restored task at version 3, got %+v", got)
+		}
+		if err := repos.Tasks.RestoreTask(ctx, tasks[1].ID, 3); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound restoring a task outside the trash, got %v", err)
+		}
+
+		if purged, err := repos.Tasks.PurgeDeletedTasks(ctx, base.Add(time.Minute)); err != nil || purged != 1 {
+			t.Errorf("Expected 1 task purged, got %d, %v", purged, err)
+		}
+		if got, _ := repos.Tasks.GetDeletedTask(ctx, tasks[0].ID); got != nil {
+			t.Errorf("Expected the purged task to be gone, got %+v", got)
+		}
+
+		idle := models.User{Name: "Idle", Email: "idle@example.com"}
+		if err := repos.Users.CreateUser(ctx, &idle); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		if err := repos.Users.DeleteUser(ctx, idle.ID, 1, base); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		if got, _ := repos.Users.GetUserByEmail(ctx, idle.Email); got != nil {
+			t.Errorf("Expected a deleted user to be hidden, got %+v", got)
+		}
+		if users, err := repos.Users.ListDeletedUsers(ctx); err != nil || len(users) != 1 || users[0].ID != idle.ID {
+			t.Errorf("Expected the deleted user in the trash, got %+v, %v", users, err)
+		}
+		if err := repos.Users.RestoreUser(ctx, idle.ID, 2); err != nil {
+			t.Fatalf("RestoreUser failed: %v", err)
+		}
+		if got, _ := repos.Users.GetUserByID(ctx, idle.ID); got == nil || got.Version != 3 {
+			t.Errorf("Expected the restored user at version 3, got %+v", got)
+		}
+		if err := repos.Users.DeleteUser(ctx, idle.ID, 3, base); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		if err := repos.Users.DeleteUser(ctx, user.ID, user.Version, base); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		if purged, err := repos.Users.PurgeDeletedUsers(ctx, base.Add(time.Minute)); err != nil || purged != 1 {
+			t.Errorf("Expected 1 user purged, got %d, %v", purged, err)
+		}
+		if got, _ := repos.Users.GetDeletedUser(ctx, idle.ID); got != nil {
+			t.Errorf("Expected the purged user to be gone, got %+v", got)
+		}
+		if got, _ := repos.Users.GetDeletedUser(ctx, user.ID); got == nil {
+			t.Error("Expected a user who still owns tasks to stay in the trash")
+		}
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Keyholder", Email: "keys@example.com", Role: "user"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		key := models.APIKey{UserID: user.ID, Hash: strings.Repeat("ab", 32), CreatedAt: time.Now().UTC().Truncate(time.Second)}
+		if err := repos.APIKeys.CreateAPIKey(ctx, &key); err != nil {
+			t.Fatalf("CreateAPIKey failed: %v", err)
+		}
+
+		got, err := repos.APIKeys.GetAPIKeyByHash(ctx, key.Hash)
+		if err != nil || got == nil || got.UserID != user.ID {
+			t.Errorf("GetAPIKeyByHash returned %+v, %v", got, err)
+		}
+		if got, err := repos.APIKeys.GetAPIKeyByHash(ctx, strings.Repeat("cd", 32)); err != nil || got != nil {
+			t.Errorf("Expected nil, nil for an unknown hash, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		repos := newRepos(t)
+		user := models.User{Name: "Sessions", Email: "sessions@example.com", Role: "user"}
+		if err := repos.Users.CreateUser(ctx, &user); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		var ids []string
+		for i := range 2 {
+			session := models.Session{ID: fmt.Sprintf("%032d", i+1), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			if err := repos.Sessions.CreateSession(ctx, &session); err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			ids = append(ids, session.ID)
+		}
+
+		got, err := repos.Sessions.GetSession(ctx, ids[0])
+		if err != nil || got == nil || !got.Active(now) {
+			t.Fatalf("Expected an active session, got %+v, %v", got, err)
+		}
+
+		if err := repos.Sessions.RevokeSession(ctx, ids[0], now); err != nil {
+			t.Fatalf("RevokeSession failed: %v", err)
+		}
+		if got, _ := repos.Sessions.GetSession(ctx, ids[0]); got == nil || got.Active(now) {
+			t.Errorf("Expected the session to be revoked, got %+v", got)
+		}
+		if revoked, err := repos.Sessions.RevokeUserSessions(ctx, user.ID, now); err != nil || revoked != 1 {
+			t.Errorf("RevokeUserSessions returned %d, %v", revoked, err)
+		}
+		if got, err := repos.Sessions.GetSession(ctx, "missing"); err != nil || got != nil {
+			t.Errorf("Expected nil, nil for an unknown session, got %+v, %v", got, err)
+		}
+
+		expired := models.Session{ID: fmt.Sprintf("%032d", 3), UserID: user.ID, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}
+		if err := repos.Sessions.CreateSession(ctx, &expired); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if purged, err := repos.Sessions.PurgeExpiredSessions(ctx, now); err != nil || purged != 1 {
+			t.Errorf("PurgeExpiredSessions returned %d, %v", purged, err)
+		}
+		if got, _ := repos.Sessions.GetSession(ctx, expired.ID); got != nil {
+			t.Errorf("Expected the expired session to be purged, got %+v", got)
+		}
+		if got, _ := repos.Sessions.GetSession(ctx, ids[0]); got == nil {
+			t.Error("Expected an unexpired session to be kept")
+		}
+	})
+
+	t.Run("IdempotencyKeys", func(t *testing.T) {
+		repos := newRepos(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		reservations := 0
+		record := func(key string, expires time.Time) *models.IdempotencyRecord {
+			reservations++
+			return &models.IdempotencyRecord{Key: key, Owner: fmt.Sprintf("%032d", reservations),
+				RequestHash: strings.Repeat("ab", 32), CreatedAt: now, ExpiresAt: expires}
+		}
+		live, expired := strings.Repeat("1", 64), strings.Repeat("2", 64)
+
+		reserved := record(live, now.Add(time.Hour))
+		for _, r := range []*models.IdempotencyRecord{reserved, record(expired, now.Add(-time.Minute))} {
+			if existing, err := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, r); err != nil || existing != nil {
+				t.Fatalf("ReserveIdempotencyKey returned %+v, %v", existing, err)
+			}
+		}
+		existing, err := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, record(live, now.Add(time.Hour)))
+		if err != nil || existing == nil || existing.Completed() {
+			t.Fatalf("Expected the pending reservation, got %+v, %v", existing, err)
+		}
+		replaced := record(expired, now.Add(time.Hour))
+		if existing, err := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, replaced); err != nil || existing != nil {
+			t.Errorf("Expected an expired record to be replaced, got %+v, %v", existing, err)
+		}
+
+		done := *reserved
+		done.Status, done.Header, done.Body = 201, http.Header{"Location": {"/tasks/7"}}, []byte(`{"id":7}`)
+		if err := repos.IdempotencyKeys.CompleteIdempotencyKey(ctx, &done); err != nil {
+			t.Fatalf("CompleteIdempotencyKey failed: %v", err)
+		}
+		if err := repos.IdempotencyKeys.ReleaseIdempotencyKey(ctx, reserved); err != nil {
+			t.Fatalf("ReleaseIdempotencyKey failed: %v", err)
+		}
+		got, err := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, record(live, now.Add(time.Hour)))
+		if err != nil || got == nil || got.Status != 201 || got.Header.Get("Location") != "/tasks/7" || string(got.Body) != `{"id":7}` {
+			t.Fatalf("Expected the completed response to survive a release, got %+v, %v", got, err)
+		}
+		if err := repos.IdempotencyKeys.CompleteIdempotencyKey(ctx, record(strings.Repeat("3", 64), now)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for an unreserved key, got %v", err)
+		}
+		if err := repos.IdempotencyKeys.CompleteIdempotencyKey(ctx, &done); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a completed key, got %v", err)
+		}
+
+		// A reservation whose lease ran out is taken over by the next
+		// request, and the original can no longer complete or release it.
+		stale := strings.Repeat("4", 64)
+		original := record(stale, now.Add(time.Second))
+		repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, original)
+		retry := record(stale, now.Add(time.Minute))
+		retry.CreatedAt = now.Add(2 * time.Second)
+		if existing, err := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, retry); err != nil || existing != nil {
+			t.Errorf("Expected a stale reservation to be taken over, got %+v, %v", existing, err)
+		}
+		late := *original
+		late.Status, late.ExpiresAt = 201, now.Add(time.Hour)
+		if err := repos.IdempotencyKeys.CompleteIdempotencyKey(ctx, &late); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound completing a reservation that was taken over, got %v", err)
+		}
+		if err := repos.IdempotencyKeys.ReleaseIdempotencyKey(ctx, original); err != nil {
+			t.Fatalf("ReleaseIdempotencyKey failed: %v", err)
+		}
+		if got, _ := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, record(stale, now.Add(time.Hour))); got == nil || got.Owner != retry.Owner {
+			t.Errorf("Expected the retry's reservation to survive the original's release, got %+v", got)
+		}
+		done = *retry
+		done.Status, done.ExpiresAt = 201, now.Add(time.Hour)
+		if err := repos.IdempotencyKeys.CompleteIdempotencyKey(ctx, &done); err != nil {
+			t.Fatalf("CompleteIdempotencyKey failed: %v", err)
+		}
+		later := record(stale, now.Add(time.Hour))
+		later.CreatedAt = now.Add(time.Minute)
+		if got, _ := repos.IdempotencyKeys.ReserveIdempotencyKey(ctx, later); got == nil || got.Status != 201 {
+			t.Errorf("Expected completing to extend the record past its lease, got %+v", got)
+		}
+
+		if err := repos.IdempotencyKeys.ReleaseIdempotencyKey(ctx, replaced); err != nil {
+			t.Fatalf("ReleaseIdempotencyKey failed: %v", err)
+		}
+		if purged, err := repos.IdempotencyKeys.DeleteExpiredIdempotencyKeys(ctx, now.Add(2*time.Hour)); err != nil || purged != 2 {
+			t.Errorf("DeleteExpiredIdempotencyKeys returned %d, %v", purged, err)
+		}
+	})
+
+	t.Run("AuditEvents", func(t *testing.T) {
+		repos := newRepos(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		events := []*models.AuditEvent{
+			{ActorID: 1, Action: models.AuditCreate, EntityType: models.AuditEntityTask, EntityID: 7, RequestID: "req-1", CreatedAt: now.Add(-time.Hour),
+				Changes: map[string]models.AuditChange{"title": {After: []byte(`"Draft"`)}}},
+			{ActorID: 1, Action: models.AuditUpdate, EntityType: models.AuditEntityTask, EntityID: 7, RequestID: "req-2", CreatedAt: now,
+				Changes: map[string]models.AuditChange{"title": {Before: []byte(`"Draft"`), After: []byte(`"Final"`)}}},
+			{ActorID: 2, Action: models.AuditDelete, EntityType: models.AuditEntityUser, EntityID: 1, CreatedAt: now,
+				Changes: map[string]models.AuditChange{"password": {}}},
+		}
+		for _, event := range events {
+			if err := repos.Audit.RecordAuditEvent(ctx, event); err != nil || event.ID == 0 {
+				t.Fatalf("RecordAuditEvent returned id %d, %v", event.ID, err)
+			}
+		}
+
+		ids := func(events []models.AuditEvent) []int {
+			var out []int
+			for _, e := range events {
+				out = append(out, e.ID)
+			}
+			return out
+		}
+		all, next, err := repos.Audit.ListAuditEvents(ctx, models.AuditQuery{})
+		if err != nil || next != 0 || fmt.Sprint(ids(all)) != fmt.Sprint([]int{events[2].ID, events[1].ID, events[0].ID}) {
+			t.Fatalf("Expected every event newest first, got %v, %d, %v", ids(all), next, err)
+		}
+		if got := all[1]; got.RequestID != "req-2" || string(got.Changes["title"].Before) != `"Draft"` || string(got.Changes["title"].After) != `"Final"` {
+			t.Errorf("Unexpected event after round trip: %+v", got)
+		}
+
+		page, next, err := repos.Audit.ListAuditEvents(ctx, models.AuditQuery{Limit: 2})
+		if err != nil || len(page) != 2 || next != events[1].ID {
+			t.Fatalf("Expected a first page of 2, got %v, %d, %v", ids(page), next, err)
+		}
+		if page, next, err = repos.Audit.ListAuditEvents(ctx, models.AuditQuery{Limit: 2, Before: next}); err != nil || len(page) != 1 || next != 0 {
+			t.Errorf("Expected the last event on the second page, got %v, %d, %v", ids(page), next, err)
+		}
+
+		queries := map[string]struct {
+			q    models.AuditQuery
+			want []int
+		}{
+			"entity":  {models.AuditQuery{EntityType: models.AuditEntityTask, EntityID: 7}, []int{events[1].ID, events[0].ID}},
+			"actor":   {models.AuditQuery{ActorID: 2}, []int{events[2].ID}},
+			"action":  {models.AuditQuery{Action: models.AuditCreate}, []int{events[0].ID}},
+			"request": {models.AuditQuery{RequestID: "req-2"}, []int{events[1].ID}},
+			"since":   {models.AuditQuery{Since: timePtr(now.Add(-time.Minute))}, []int{events[2].ID, events[1].ID}},
+			"until":   {models.AuditQuery{Until: timePtr(now.Add(-time.Minute))}, []int{events[0].ID}},
+		}
+		for name, c := range queries {
+			got, _, err := repos.Audit.ListAuditEvents(ctx, c.q)
+			if err != nil || fmt.Sprint(ids(got)) != fmt.Sprint(c.want) {
+				t.Errorf("%s: expected %v, got %v, %v", name, c.want, ids(got), err)
+			}
+		}
+	})
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestMemoryBackend(t *testing.T) {
+	runConformance(t, func(t *testing.T) *Repositories {
+		repos, err := New(BackendMemory, nil, Options{})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		return repos
+	})
+}
+
+// TestSQLBackend runs the suite against a database server when
+// TASKMANAGER_TEST_DIALECT and TASKMANAGER_TEST_DSN are set. The schema must
+// be migrated first (taskmanager migrate up). TestSQLiteBackend covers the
+// SQL repositories when they are not.
+func TestSQLBackend(t *testing.T) {
+	dialectName := os.Getenv("TASKMANAGER_TEST_DIALECT")
+	dsn := os.Getenv("TASKMANAGER_TEST_DSN")
+	if dialectName == "" || dsn == "" {
+		t.Skip("TASKMANAGER_TEST_DIALECT and TASKMANAGER_TEST_DSN not set")
+	}
+
+	dialect, err := ParseDialect(dialectName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to DB: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	runConformance(t, func(t *testing.T) *Repositories {
+		for _, table := range []string{"audit_events", "idempotency_keys", "sessions", "api_keys", "tasks", "users"} {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("failed to clean test DB: %v", err)
+			}
+		}
+
+		repos, err := New(string(dialect), db, Options{})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		return repos
+	})
+}
+
+func TestMemoryBackendHonoursCancellation(t *testing.T) {
+	repos, err := New(BackendMemory, nil, Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := repos.Tasks.CreateTask(ctx, &models.Task{Title: "late"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(strings.ToLower(name)); d {
+	case DialectMySQL, DialectPostgres, DialectSQLite:
+		return d, nil
+	case "postgresql", "pgx":
+		return DialectPostgres, nil
+	case "sqlite3":
+		return DialectSQLite, nil
+	}
+	return "", fmt.Errorf("unsupported SQL dialect %q", name)
+}
+
+// DriverName is the database/sql driver the binary links for the dialect:
+// go-sql-driver/mysql, pgx and modernc.org/sqlite, all pure Go.
+func (d Dialect) DriverName() string {
+	if d == DialectPostgres {
+		return "pgx"
+	}
+	return string(d)
+}
+
+// Rebind rewrites the repositories' "?" placeholders into the dialect's
+// bind syntax. None of our queries contain a literal "?".
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
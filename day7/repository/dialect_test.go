@@ -0,0 +1,16 @@
+package repository
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	query := "UPDATE tasks SET title=?, status=? WHERE id=?"
+
+	if got := DialectMySQL.Rebind(query); got != query {
+		t.Errorf("MySQL should keep ? placeholders, got %s", got)
+	}
+
+	want := "UPDATE tasks SET title=$1, status=$2 WHERE id=$3"
+	if got := DialectPostgres.Rebind(query); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
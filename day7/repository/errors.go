@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLSTATEs the repositories react to; pgx has no constants for them.
+const (
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+var (
+	ErrNotFound   = errors.New("record not found")
+	ErrForeignKey = errors.New("foreign key constraint violated")
+	ErrDuplicate  = errors.New("unique constraint violated")
+	// ErrVersionConflict means a conditional write found the record at a
+	// different version than the one the caller read.
+	ErrVersionConflict = errors.New("record version changed")
+)
+
+// translateError maps driver errors the services care about onto the
+// repository's own errors so callers never inspect driver types.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var myErr *mysql.MySQLError
+	var pgErr *pgconn.PgError
+	var liteErr *sqlite.Error
+	switch {
+	case errors.As(err, &myErr):
+		// 1451: row is still referenced, 1452: referenced row is missing.
+		if myErr.Number == 1451 || myErr.Number == 1452 {
+			return errors.Join(ErrForeignKey, err)
+		}
+		// 1062: duplicate entry for a unique key.
+		if myErr.Number == 1062 {
+			return errors.Join(ErrDuplicate, err)
+		}
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case pgForeignKeyViolation:
+			return errors.Join(ErrForeignKey, err)
+		case pgUniqueViolation:
+			return errors.Join(ErrDuplicate, err)
+		}
+	case errors.As(err, &liteErr):
+		switch liteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return errors.Join(ErrForeignKey, err)
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return errors.Join(ErrDuplicate, err)
+		}
+	}
+	return err
+}
+
+// retryable reports whether err means the database aborted the transaction
+// to break a deadlock or a lock wait, so running it again may succeed.
+func retryable(err error) bool {
+	var myErr *mysql.MySQLError
+	var pgErr *pgconn.PgError
+	var liteErr *sqlite.Error
+	switch {
+	case errors.As(err, &myErr):
+		// 1213: deadlock found, 1205: lock wait timeout exceeded.
+		return myErr.Number == 1213 || myErr.Number == 1205
+	case errors.As(err, &pgErr):
+		return pgErr.Code == pgDeadlockDetected || pgErr.Code == pgSerializationFailure
+	case errors.As(err, &liteErr):
+		// The low byte is the primary result code of an extended one.
+		code := liteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
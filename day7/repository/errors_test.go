@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{&mysql.MySQLError{Number: 1452}, ErrForeignKey},
+		{&mysql.MySQLError{Number: 1062}, ErrDuplicate},
+		{&pgconn.PgError{Code: "23503"}, ErrForeignKey},
+		{&pgconn.PgError{Code: "23505"}, ErrDuplicate},
+		{&pgconn.PgError{Code: "23514", Message: "title 'foreign key 23505' violates check"}, nil},
+		{errors.New("FOREIGN KEY constraint failed"), nil},
+	}
+	for _, tt := range tests {
+		got := translateError(tt.err)
+		for _, sentinel := range []error{ErrForeignKey, ErrDuplicate} {
+			if errors.Is(got, sentinel) != (sentinel == tt.want) {
+				t.Errorf("translateError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package repository
+
+// RunConformance lets the external tests, which may import packages that
+// depend on this one such as migrations, run the shared suite.
+var RunConformance = runConformance
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"taskmanager/models"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey stores record unless a record that has not
+	// expired already holds its key, in which case that one is returned and
+	// nothing is written. Expired records are replaced.
+	ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response of a reserved key and
+	// keeps it until record.ExpiresAt. It returns ErrNotFound once the key
+	// is no longer reserved by record.Owner.
+	CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error
+	// ReleaseIdempotencyKey drops a reservation whose request produced no
+	// response worth replaying, if record.Owner still holds it. Completed
+	// records are kept.
+	ReleaseIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error)
+}
+
+type idempotencyRepository struct {
+	sqlStore
+}
+
+func NewIdempotencyRepository(db *sql.DB, dialect Dialect, opts Options) IdempotencyRepository {
+	return &idempotencyRepository{sqlStore: newSQLStore(db, dialect, opts)}
+}
+
+func (r *idempotencyRepository) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return nil, err
+	}
+	query := "INSERT INTO idempotency_keys (id, owner, request_hash, status, header, body, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+	// A second attempt is only needed when the first found an expired
+	// record in the way.
+	for range 2 {
+		_, err := r.exec(ctx, query, record.Key, record.Owner, record.RequestHash, record.Status, string(header),
+			nonNil(record.Body), record.CreatedAt, record.ExpiresAt)
+		if err = translateError(err); !errors.Is(err, ErrDuplicate) {
+			return nil, err
+		}
+
+		existing, err := r.getIdempotencyKey(ctx, record.Key)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.ExpiresAt.After(record.CreatedAt) {
+			return existing, nil
+		}
+		if _, err := r.exec(ctx, "DELETE FROM idempotency_keys WHERE id = ? AND expires_at <= ?", record.Key, record.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("idempotency key %s: %w", record.Key, ErrDuplicate)
+}
+
+func (r *idempotencyRepository) getIdempotencyKey(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	query := "SELECT id, owner, request_hash, status, header, body, created_at, expires_at FROM idempotency_keys WHERE id = ?"
+
+	var record models.IdempotencyRecord
+	var header string
+	err := r.queryRow(ctx, query, key).Scan(&record.Key, &record.Owner, &record.RequestHash, &record.Status, &header,
+		&record.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(header), &record.Header); err != nil {
+		return nil, fmt.Errorf("idempotency key %s: decoding headers: %w", key, err)
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE idempotency_keys SET status = ?, header = ?, body = ?, expires_at = ? WHERE id = ? AND owner = ? AND status = 0"
+	result, err := r.exec(ctx, query, record.Status, string(header), nonNil(record.Body), record.ExpiresAt, record.Key, record.Owner)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	_, err := r.exec(ctx, "DELETE FROM idempotency_keys WHERE id = ? AND owner = ? AND status = 0", record.Key, record.Owner)
+	return err
+}
+
+func (r *idempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	result, err := r.exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// nonNil keeps an empty body from being written as NULL.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
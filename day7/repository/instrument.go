@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"taskmanager/models"
+	"taskmanager/tracing"
+	"time"
+)
+
+// Operation names a repository call, e.g. {"tasks", "ListTasks"}.
+type Operation struct {
+	Repository string
+	Method     string
+}
+
+// Hook observes repository calls. It runs before the call and may return a
+// derived context for it; the returned func runs with the call's error once
+// it completes.
+type Hook func(ctx context.Context, op Operation) (context.Context, func(err error))
+
+// Instrument wraps every repository but Tx so each call goes through hooks,
+// in order.
+func Instrument(repos *Repositories, hooks ...Hook) *Repositories {
+	if len(hooks) == 0 {
+		return repos
+	}
+	return &Repositories{
+		Tasks:           &instrumentedTasks{next: repos.Tasks, hooks: hooks},
+		Users:           &instrumentedUsers{next: repos.Users, hooks: hooks},
+		APIKeys:         &instrumentedAPIKeys{next: repos.APIKeys, hooks: hooks},
+		Sessions:        &instrumentedSessions{next: repos.Sessions, hooks: hooks},
+		IdempotencyKeys: &instrumentedIdempotencyKeys{next: repos.IdempotencyKeys, hooks: hooks},
+		Audit:           &instrumentedAudit{next: repos.Audit, hooks: hooks},
+		Tx:              repos.Tx,
+	}
+}
+
+// Trace records a span for each repository call, named like "tasks.ListTasks".
+// The statements the call runs become its children.
+func Trace(tracer *tracing.Tracer) Hook {
+	return func(ctx context.Context, op Operation) (context.Context, func(error)) {
+		ctx, span := tracer.Start(ctx, op.Repository+"."+op.Method, tracing.KindInternal,
+			slog.String("component", "repository"))
+		return ctx, span.Finish
+	}
+}
+
+func start(ctx context.Context, hooks []Hook, op Operation) (context.Context, func(error)) {
+	dones := make([]func(error), len(hooks))
+	for i, hook := range hooks {
+		ctx, dones[i] = hook(ctx, op)
+	}
+	return ctx, func(err error) {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](err)
+		}
+	}
+}
+
+type instrumentedTasks struct {
+	next  TaskRepository
+	hooks []Hook
+}
+
+func (r *instrumentedTasks) CreateTask(ctx context.Context, task *models.Task) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "CreateTask"})
+	defer func() { done(err) }()
+	return r.next.CreateTask(ctx, task)
+}
+
+func (r *instrumentedTasks) GetTaskByID(ctx context.Context, id int) (task *models.Task, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "GetTaskByID"})
+	defer func() { done(err) }()
+	return r.next.GetTaskByID(ctx, id)
+}
+
+func (r *instrumentedTasks) GetTasksByUserID(ctx context.Context, id int) (tasks []models.Task, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "GetTasksByUserID"})
+	defer func() { done(err) }()
+	return r.next.GetTasksByUserID(ctx, id)
+}
+
+func (r *instrumentedTasks) ListTasks(ctx context.Context, q models.TaskQuery) (tasks []models.Task, cursor *models.TaskCursor, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "ListTasks"})
+	defer func() { done(err) }()
+	return r.next.ListTasks(ctx, q)
+}
+
+func (r *instrumentedTasks) CountTasksByUserID(ctx context.Context, userID int) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "CountTasksByUserID"})
+	defer func() { done(err) }()
+	return r.next.CountTasksByUserID(ctx, userID)
+}
+
+func (r *instrumentedTasks) UpdateTask(ctx context.Context, task *models.Task) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "UpdateTask"})
+	defer func() { done(err) }()
+	return r.next.UpdateTask(ctx, task)
+}
+
+func (r *instrumentedTasks) DeleteTask(ctx context.Context, id, version int, at time.Time) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "DeleteTask"})
+	defer func() { done(err) }()
+	return r.next.DeleteTask(ctx, id, version, at)
+}
+
+func (r *instrumentedTasks) DeleteTasksByUserID(ctx context.Context, userID int, at time.Time) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "DeleteTasksByUserID"})
+	defer func() { done(err) }()
+	return r.next.DeleteTasksByUserID(ctx, userID, at)
+}
+
+func (r *instrumentedTasks) ReassignTasks(ctx context.Context, fromUserID, toUserID int) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "ReassignTasks"})
+	defer func() { done(err) }()
+	return r.next.ReassignTasks(ctx, fromUserID, toUserID)
+}
+
+func (r *instrumentedTasks) GetDeletedTask(ctx context.Context, id int) (task *models.Task, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "GetDeletedTask"})
+	defer func() { done(err) }()
+	return r.next.GetDeletedTask(ctx, id)
+}
+
+func (r *instrumentedTasks) ListDeletedTasks(ctx context.Context, userID int) (tasks []models.Task, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "ListDeletedTasks"})
+	defer func() { done(err) }()
+	return r.next.ListDeletedTasks(ctx, userID)
+}
+
+func (r *instrumentedTasks) RestoreTask(ctx context.Context, id, version int) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "RestoreTask"})
+	defer func() { done(err) }()
+	return r.next.RestoreTask(ctx, id, version)
+}
+
+func (r *instrumentedTasks) PurgeDeletedTasks(ctx context.Context, before time.Time) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "PurgeDeletedTasks"})
+	defer func() { done(err) }()
+	return r.next.PurgeDeletedTasks(ctx, before)
+}
+
+func (r *instrumentedTasks) CreateTasks(ctx context.Context, tasks []*models.Task) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "CreateTasks"})
+	defer func() { done(err) }()
+	return r.next.CreateTasks(ctx, tasks)
+}
+
+func (r *instrumentedTasks) ApplyBatch(ctx context.Context, ops []TaskOp, atomic bool) (errs []error, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"tasks", "ApplyBatch"})
+	defer func() { done(err) }()
+	return r.next.ApplyBatch(ctx, ops, atomic)
+}
+
+type instrumentedUsers struct {
+	next  UserRepository
+	hooks []Hook
+}
+
+func (r *instrumentedUsers) CreateUser(ctx context.Context, user *models.User) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "CreateUser"})
+	defer func() { done(err) }()
+	return r.next.CreateUser(ctx, user)
+}
+
+func (r *instrumentedUsers) GetUserByID(ctx context.Context, id int) (user *models.User, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "GetUserByID"})
+	defer func() { done(err) }()
+	return r.next.GetUserByID(ctx, id)
+}
+
+func (r *instrumentedUsers) GetUserByEmail(ctx context.Context, email string) (user *models.User, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "GetUserByEmail"})
+	defer func() { done(err) }()
+	return r.next.GetUserByEmail(ctx, email)
+}
+
+func (r *instrumentedUsers) UpdatePasswordHash(ctx context.Context, id, version int, hash string) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "UpdatePasswordHash"})
+	defer func() { done(err) }()
+	return r.next.UpdatePasswordHash(ctx, id, version, hash)
+}
+
+func (r *instrumentedUsers) DeleteUser(ctx context.Context, id, version int, at time.Time) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "DeleteUser"})
+	defer func() { done(err) }()
+	return r.next.DeleteUser(ctx, id, version, at)
+}
+
+func (r *instrumentedUsers) GetDeletedUser(ctx context.Context, id int) (user *models.User, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "GetDeletedUser"})
+	defer func() { done(err) }()
+	return r.next.GetDeletedUser(ctx, id)
+}
+
+func (r *instrumentedUsers) ListDeletedUsers(ctx context.Context) (users []models.User, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "ListDeletedUsers"})
+	defer func() { done(err) }()
+	return r.next.ListDeletedUsers(ctx)
+}
+
+func (r *instrumentedUsers) RestoreUser(ctx context.Context, id, version int) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "RestoreUser"})
+	defer func() { done(err) }()
+	return r.next.RestoreUser(ctx, id, version)
+}
+
+func (r *instrumentedUsers) PurgeDeletedUsers(ctx context.Context, before time.Time) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"users", "PurgeDeletedUsers"})
+	defer func() { done(err) }()
+	return r.next.PurgeDeletedUsers(ctx, before)
+}
+
+type instrumentedAPIKeys struct {
+	next  APIKeyRepository
+	hooks []Hook
+}
+
+func (r *instrumentedAPIKeys) CreateAPIKey(ctx context.Context, key *models.APIKey) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"api_keys", "CreateAPIKey"})
+	defer func() { done(err) }()
+	return r.next.CreateAPIKey(ctx, key)
+}
+
+func (r *instrumentedAPIKeys) GetAPIKeyByHash(ctx context.Context, hash string) (key *models.APIKey, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"api_keys", "GetAPIKeyByHash"})
+	defer func() { done(err) }()
+	return r.next.GetAPIKeyByHash(ctx, hash)
+}
+
+type instrumentedSessions struct {
+	next  SessionRepository
+	hooks []Hook
+}
+
+func (r *instrumentedSessions) CreateSession(ctx context.Context, session *models.Session) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"sessions", "CreateSession"})
+	defer func() { done(err) }()
+	return r.next.CreateSession(ctx, session)
+}
+
+func (r *instrumentedSessions) GetSession(ctx context.Context, id string) (session *models.Session, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"sessions", "GetSession"})
+	defer func() { done(err) }()
+	return r.next.GetSession(ctx, id)
+}
+
+func (r *instrumentedSessions) RevokeSession(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"sessions", "RevokeSession"})
+	defer func() { done(err) }()
+	return r.next.RevokeSession(ctx, id, at)
+}
+
+func (r *instrumentedSessions) RevokeUserSessions(ctx context.Context, userID int, at time.Time) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"sessions", "RevokeUserSessions"})
+	defer func() { done(err) }()
+	return r.next.RevokeUserSessions(ctx, userID, at)
+}
+
+func (r *instrumentedSessions) PurgeExpiredSessions(ctx context.Context, now time.Time) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"sessions", "PurgeExpiredSessions"})
+	defer func() { done(err) }()
+	return r.next.PurgeExpiredSessions(ctx, now)
+}
+
+type instrumentedIdempotencyKeys struct {
+	next  IdempotencyRepository
+	hooks []Hook
+}
+
+func (r *instrumentedIdempotencyKeys) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (existing *models.IdempotencyRecord, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"idempotency_keys", "ReserveIdempotencyKey"})
+	defer func() { done(err) }()
+	return r.next.ReserveIdempotencyKey(ctx, record)
+}
+
+func (r *instrumentedIdempotencyKeys) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"idempotency_keys", "CompleteIdempotencyKey"})
+	defer func() { done(err) }()
+	return r.next.CompleteIdempotencyKey(ctx, record)
+}
+
+func (r *instrumentedIdempotencyKeys) ReleaseIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"idempotency_keys", "ReleaseIdempotencyKey"})
+	defer func() { done(err) }()
+	return r.next.ReleaseIdempotencyKey(ctx, record)
+}
+
+func (r *instrumentedIdempotencyKeys) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (n int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"idempotency_keys", "DeleteExpiredIdempotencyKeys"})
+	defer func() { done(err) }()
+	return r.next.DeleteExpiredIdempotencyKeys(ctx, now)
+}
+
+type instrumentedAudit struct {
+	next  AuditRepository
+	hooks []Hook
+}
+
+func (r *instrumentedAudit) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) (err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"audit_events", "RecordAuditEvent"})
+	defer func() { done(err) }()
+	return r.next.RecordAuditEvent(ctx, event)
+}
+
+func (r *instrumentedAudit) ListAuditEvents(ctx context.Context, q models.AuditQuery) (events []models.AuditEvent, next int, err error) {
+	ctx, done := start(ctx, r.hooks, Operation{"audit_events", "ListAuditEvents"})
+	defer func() { done(err) }()
+	return r.next.ListAuditEvents(ctx, q)
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"taskmanager/models"
+	"taskmanager/tracing"
+	"testing"
+	"time"
+)
+
+func TestInstrumentedBackend(t *testing.T) {
+	runConformance(t, func(t *testing.T) *Repositories {
+		repos, err := New(BackendMemory, nil, Options{})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		return Instrument(repos, func(ctx context.Context, _ Operation) (context.Context, func(error)) {
+			return ctx, func(error) {}
+		})
+	})
+}
+
+func TestInstrumentRunsHooks(t *testing.T) {
+	repos, err := New(BackendMemory, nil, Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	type key struct{}
+	var calls []string
+	hook := func(name string) Hook {
+		return func(ctx context.Context, op Operation) (context.Context, func(error)) {
+			calls = append(calls, name+" start "+op.Repository+"."+op.Method)
+			return context.WithValue(ctx, key{}, name), func(err error) {
+				calls = append(calls, name+" done "+errString(err))
+			}
+		}
+	}
+	repos = Instrument(repos, hook("outer"), hook("inner"))
+
+	if err := repos.Tasks.DeleteTask(context.Background(), 42, 1, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound to pass through, got %v", err)
+	}
+	want := []string{
+		"outer start tasks.DeleteTask",
+		"inner start tasks.DeleteTask",
+		"inner done " + ErrNotFound.Error(),
+		"outer done " + ErrNotFound.Error(),
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("Expected %q, got %q", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("Call %d: expected %q, got %q", i, want[i], calls[i])
+		}
+	}
+
+	calls = nil
+	if err := repos.Users.CreateUser(context.Background(), &models.User{Name: "a", Email: "a@example.com"}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if calls[len(calls)-1] != "outer done <nil>" {
+		t.Errorf("Expected a nil error for a successful call, got %q", calls)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+func TestTraceHook(t *testing.T) {
+	repos, err := New(BackendMemory, nil, Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	rec := &tracing.Recorder{}
+	repos = Instrument(repos, Trace(tracing.NewTracer(rec)))
+
+	if _, err := repos.Tasks.GetTaskByID(context.Background(), 7); err != nil {
+		t.Fatalf("GetTaskByID failed: %v", err)
+	}
+	repos.Tasks.DeleteTask(context.Background(), 7, 1, time.Now())
+
+	spans := rec.Spans()
+	if len(spans) != 2 || spans[0].Name != "tasks.GetTaskByID" || spans[0].Status != "ok" {
+		t.Fatalf("Unexpected spans %+v", spans)
+	}
+	if spans[1].Name != "tasks.DeleteTask" || spans[1].StatusMessage != ErrNotFound.Error() {
+		t.Errorf("Expected the error on the span, got %+v", spans[1])
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"taskmanager/models"
+)
+
+type memoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	keys   map[string]models.APIKey
+	nextID int
+}
+
+func NewMemoryAPIKeyRepository() APIKeyRepository {
+	return &memoryAPIKeyRepository{
+		keys: make(map[string]models.APIKey),
+	}
+}
+
+func (r *memoryAPIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	key.ID = r.nextID
+	r.keys[key.Hash] = *key
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
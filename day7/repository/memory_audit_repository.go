@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"taskmanager/models"
+)
+
+type memoryAuditRepository struct {
+	mu     sync.RWMutex
+	events []models.AuditEvent
+}
+
+func NewMemoryAuditRepository() AuditRepository {
+	return &memoryAuditRepository{}
+}
+
+func (r *memoryAuditRepository) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(r.events)
+	event.ID = n + 1
+	r.events = append(r.events, *event)
+	logUndo(ctx, &r.mu, func() { r.events = r.events[:n] })
+	return nil
+}
+
+func (r *memoryAuditRepository) ListAuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []models.AuditEvent
+	for _, event := range slices.Backward(r.events) {
+		if matchesAuditQuery(&event, &q) {
+			events = append(events, event)
+		}
+	}
+	events, next := auditPageOf(events, q.Limit)
+	return events, next, nil
+}
+
+func matchesAuditQuery(event *models.AuditEvent, q *models.AuditQuery) bool {
+	switch {
+	case q.ActorID != 0 && event.ActorID != q.ActorID,
+		q.Action != "" && event.Action != q.Action,
+		q.EntityType != "" && event.EntityType != q.EntityType,
+		q.EntityID != 0 && event.EntityID != q.EntityID,
+		q.RequestID != "" && event.RequestID != q.RequestID,
+		q.Since != nil && event.CreatedAt.Before(*q.Since),
+		q.Until != nil && !event.CreatedAt.Before(*q.Until),
+		q.Before != 0 && event.ID >= q.Before:
+		return false
+	}
+	return true
+}
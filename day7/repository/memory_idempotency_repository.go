@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"taskmanager/models"
+	"time"
+)
+
+type memoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func NewMemoryIdempotencyRepository() IdempotencyRepository {
+	return &memoryIdempotencyRepository{
+		records: make(map[string]models.IdempotencyRecord),
+	}
+}
+
+func (r *memoryIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.records[record.Key]; ok && existing.ExpiresAt.After(record.CreatedAt) {
+		return &existing, nil
+	}
+	r.records[record.Key] = *record
+	return nil, nil
+}
+
+func (r *memoryIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.records[record.Key]
+	if !ok || stored.Owner != record.Owner || stored.Completed() {
+		return ErrNotFound
+	}
+	stored.Status, stored.Header, stored.Body = record.Status, record.Header, record.Body
+	stored.ExpiresAt = record.ExpiresAt
+	r.records[record.Key] = stored
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.records[record.Key]; ok && stored.Owner == record.Owner && !stored.Completed() {
+		delete(r.records, record.Key)
+	}
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for key, record := range r.records {
+		if !record.ExpiresAt.After(now) {
+			delete(r.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"taskmanager/models"
+	"time"
+)
+
+type memorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+}
+
+func NewMemorySessionRepository() SessionRepository {
+	return &memorySessionRepository{
+		sessions: make(map[string]models.Session),
+	}
+}
+
+func (r *memorySessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; ok {
+		return ErrDuplicate
+	}
+	r.put(ctx, *session)
+	return nil
+}
+
+func (r *memorySessionRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if ok && session.RevokedAt == nil {
+		session.RevokedAt = &at
+		r.put(ctx, session)
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeUserSessions(ctx context.Context, userID int, at time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revoked := 0
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			r.put(ctx, session)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r *memorySessionRepository) PurgeExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, session := range r.sessions {
+		if !session.ExpiresAt.After(now) {
+			logUndo(ctx, &r.mu, deleteEntry(r.sessions, id))
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// put stores session and logs how to undo the write. r.mu must be held.
+func (r *memorySessionRepository) put(ctx context.Context, session models.Session) {
+	logUndo(ctx, &r.mu, putEntry(r.sessions, session.ID, session))
+}
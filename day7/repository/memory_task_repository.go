@@ -0,0 +1,403 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"taskmanager/models"
+	"time"
+)
+
+type memoryTaskRepository struct {
+	mu     sync.RWMutex
+	tasks  map[int]models.Task
+	nextID int
+}
+
+func NewMemoryTaskRepository() TaskRepository {
+	return &memoryTaskRepository{
+		tasks: make(map[int]models.Task),
+	}
+}
+
+func (r *memoryTaskRepository) CreateTask(ctx context.Context, task *models.Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	task.ID = r.nextID
+	task.Version = 1
+	r.put(ctx, *task)
+	return nil
+}
+
+func (r *memoryTaskRepository) GetTaskByID(ctx context.Context, id int) (*models.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	task, ok := r.tasks[id]
+	if !ok || task.DeletedAt != nil {
+		return nil, nil
+	}
+	return &task, nil
+}
+
+func (r *memoryTaskRepository) GetDeletedTask(ctx context.Context, id int) (*models.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	task, ok := r.tasks[id]
+	if !ok || task.DeletedAt == nil {
+		return nil, nil
+	}
+	return &task, nil
+}
+
+func (r *memoryTaskRepository) GetTasksByUserID(ctx context.Context, id int) ([]models.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tasks []models.Task
+	for _, task := range r.tasks {
+		if task.UserId == id && task.DeletedAt == nil {
+			tasks = append(tasks, task)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) ListDeletedTasks(ctx context.Context, userID int) ([]models.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tasks []models.Task
+	for _, task := range r.tasks {
+		if task.UserId == userID && task.DeletedAt != nil {
+			tasks = append(tasks, task)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].DeletedAt.Equal(*tasks[j].DeletedAt) {
+			return tasks[i].DeletedAt.After(*tasks[j].DeletedAt)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) ListTasks(ctx context.Context, q models.TaskQuery) ([]models.Task, *models.TaskCursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	key, err := sortKeyFor(q.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var after any
+	if q.After != nil {
+		if after, err = cursorValue(key, q.After); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// order returns <0 when a sorts before b in the requested direction.
+	order := func(a *models.Task, aKey any, b *models.Task, bKey any) int {
+		c := compareKeys(aKey, bKey)
+		if c == 0 {
+			c = a.ID - b.ID
+		}
+		if q.Sort.Desc {
+			return -c
+		}
+		return c
+	}
+	cursorTask := &models.Task{}
+	if q.After != nil {
+		cursorTask.ID = q.After.ID
+	}
+
+	r.mu.RLock()
+	var tasks []models.Task
+	for _, task := range r.tasks {
+		if !matchesQuery(&task, &q) {
+			continue
+		}
+		if q.After != nil && order(&task, key.key(&task), cursorTask, after) <= 0 {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return order(&tasks[i], key.key(&tasks[i]), &tasks[j], key.key(&tasks[j])) < 0
+	})
+
+	tasks, next := pageOf(key, tasks, q.Limit)
+	return tasks, next, nil
+}
+
+func matchesQuery(task *models.Task, q *models.TaskQuery) bool {
+	if task.UserId != q.UserID || task.DeletedAt != nil {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, task.Status) {
+		return false
+	}
+	if len(q.Priorities) > 0 && !slices.Contains(q.Priorities, task.Priority) {
+		return false
+	}
+	if q.DueBefore != nil && (task.DueDate == nil || !task.DueDate.Before(*q.DueBefore)) {
+		return false
+	}
+	if q.DueAfter != nil && (task.DueDate == nil || !task.DueDate.After(*q.DueAfter)) {
+		return false
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(task.Title), strings.ToLower(q.Search)) {
+		return false
+	}
+	return true
+}
+
+func (r *memoryTaskRepository) CountTasksByUserID(ctx context.Context, userID int) (int, error) {
+	tasks, err := r.GetTasksByUserID(ctx, userID)
+	return len(tasks), err
+}
+
+func (r *memoryTaskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tasks[task.ID]
+	if err := checkVersion(ok && stored.DeletedAt == nil, stored.Version, task.Version); err != nil {
+		return err
+	}
+	task.Version++
+	r.put(ctx, *task)
+	return nil
+}
+
+func (r *memoryTaskRepository) DeleteTask(ctx context.Context, id, version int, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tasks[id]
+	if err := checkVersion(ok && stored.DeletedAt == nil, stored.Version, version); err != nil {
+		return err
+	}
+	stored.DeletedAt = &at
+	stored.Version++
+	r.put(ctx, stored)
+	return nil
+}
+
+func (r *memoryTaskRepository) RestoreTask(ctx context.Context, id, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tasks[id]
+	if err := checkVersion(ok && stored.DeletedAt != nil, stored.Version, version); err != nil {
+		return err
+	}
+	stored.DeletedAt = nil
+	stored.Version++
+	r.put(ctx, stored)
+	return nil
+}
+
+func (r *memoryTaskRepository) PurgeDeletedTasks(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, task := range r.tasks {
+		if task.DeletedAt != nil && task.DeletedAt.Before(before) {
+			logUndo(ctx, &r.mu, deleteEntry(r.tasks, id))
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// ownsTasks reports whether any task, in the trash or not, belongs to
+// userID.
+func (r *memoryTaskRepository) ownsTasks(userID int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, task := range r.tasks {
+		if task.UserId == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVersion is the memory repositories' conditional write: ok says
+// whether the record exists and have is its stored version.
+func checkVersion(ok bool, have, want int) error {
+	if !ok {
+		return ErrNotFound
+	}
+	if have != want {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *memoryTaskRepository) DeleteTasksByUserID(ctx context.Context, userID int, at time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for _, task := range r.tasks {
+		if task.UserId == userID && task.DeletedAt == nil {
+			task.DeletedAt = &at
+			task.Version++
+			r.put(ctx, task)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryTaskRepository) ReassignTasks(ctx context.Context, fromUserID, toUserID int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moved := 0
+	for _, task := range r.tasks {
+		if task.UserId == fromUserID && task.DeletedAt == nil {
+			task.UserId = toUserID
+			task.Version++
+			r.put(ctx, task)
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func (r *memoryTaskRepository) CreateTasks(ctx context.Context, tasks []*models.Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, task := range tasks {
+		r.nextID++
+		task.ID = r.nextID
+		task.Version = 1
+		r.put(ctx, *task)
+	}
+	return nil
+}
+
+// ApplyBatch undoes the ops it applied when one fails in atomic mode.
+func (r *memoryTaskRepository) ApplyBatch(ctx context.Context, ops []TaskOp, atomic bool) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var undo []func()
+	put := func(task models.Task) {
+		undo = append(undo, putEntry(r.tasks, task.ID, task))
+	}
+
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		switch op.Action {
+		case models.BatchCreate:
+			r.nextID++
+			op.Task.ID = r.nextID
+			op.Task.Version = 1
+			put(*op.Task)
+		case models.BatchUpdate:
+			stored, ok := r.tasks[op.Task.ID]
+			if errs[i] = checkVersion(ok && stored.DeletedAt == nil, stored.Version, op.Task.Version); errs[i] == nil {
+				op.Task.Version++
+				put(*op.Task)
+			}
+		case models.BatchDelete:
+			stored, ok := r.tasks[op.Task.ID]
+			if errs[i] = checkVersion(ok && stored.DeletedAt == nil, stored.Version, op.Task.Version); errs[i] == nil {
+				stored.DeletedAt = op.Task.DeletedAt
+				stored.Version++
+				put(stored)
+			}
+		default:
+			errs[i] = fmt.Errorf("unknown batch action %q", op.Action)
+		}
+		if errs[i] != nil && atomic {
+			for _, u := range slices.Backward(undo) {
+				u()
+			}
+			return errs, nil
+		}
+	}
+
+	for _, u := range undo {
+		logUndo(ctx, &r.mu, u)
+	}
+	return errs, nil
+}
+
+// put stores task and logs how to undo the write. r.mu must be held.
+func (r *memoryTaskRepository) put(ctx context.Context, task models.Task) {
+	logUndo(ctx, &r.mu, putEntry(r.tasks, task.ID, task))
+}
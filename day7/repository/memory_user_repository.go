@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"taskmanager/models"
+	"time"
+)
+
+type memoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[int]models.User
+	nextID int
+	tasks  *memoryTaskRepository
+}
+
+// NewMemoryUserRepository returns a UserRepository whose users cannot be
+// purged while they own tasks in tasks, which must come from
+// NewMemoryTaskRepository.
+func NewMemoryUserRepository(tasks TaskRepository) UserRepository {
+	return &memoryUserRepository{
+		users: make(map[int]models.User),
+		tasks: tasks.(*memoryTaskRepository),
+	}
+}
+
+func (r *memoryUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+
+	r.nextID++
+	user.ID = r.nextID
+	user.Version = 1
+	r.put(ctx, *user)
+	return nil
+}
+
+func (r *memoryUserRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt != nil {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email && user.DeletedAt == nil {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserRepository) UpdatePasswordHash(ctx context.Context, id, version int, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if err := checkVersion(ok && user.DeletedAt == nil, user.Version, version); err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.Version++
+	r.put(ctx, user)
+	return nil
+}
+
+func (r *memoryUserRepository) DeleteUser(ctx context.Context, id, version int, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if err := checkVersion(ok && user.DeletedAt == nil, user.Version, version); err != nil {
+		return err
+	}
+	user.DeletedAt = &at
+	user.Version++
+	r.put(ctx, user)
+	return nil
+}
+
+func (r *memoryUserRepository) GetDeletedUser(ctx context.Context, id int) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt == nil {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) ListDeletedUsers(ctx context.Context) ([]models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []models.User
+	for _, user := range r.users {
+		if user.DeletedAt != nil {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].DeletedAt.Equal(*users[j].DeletedAt) {
+			return users[i].DeletedAt.After(*users[j].DeletedAt)
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+func (r *memoryUserRepository) RestoreUser(ctx context.Context, id, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if err := checkVersion(ok && user.DeletedAt != nil, user.Version, version); err != nil {
+		return err
+	}
+	user.DeletedAt = nil
+	user.Version++
+	r.put(ctx, user)
+	return nil
+}
+
+// PurgeDeletedUsers keeps the users that still own tasks, as the SQL
+// backends' foreign keys do.
+func (r *memoryUserRepository) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, user := range r.users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(before) && !r.tasks.ownsTasks(id) {
+			logUndo(ctx, &r.mu, deleteEntry(r.users, id))
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// put stores user and logs how to undo the write. r.mu must be held.
+func (r *memoryUserRepository) put(ctx context.Context, user models.User) {
+	logUndo(ctx, &r.mu, putEntry(r.users, user.ID, user))
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type Repositories struct {
+	Tasks    TaskRepository
+	Users    UserRepository
+	APIKeys  APIKeyRepository
+	Sessions SessionRepository
+	// IdempotencyKeys holds the responses replayed for retried requests.
+	IdempotencyKeys IdempotencyRepository
+	// Audit is the append-only log of changes to tasks and users.
+	Audit AuditRepository
+	// Tx runs several calls to the repositories above in one transaction.
+	Tx TxManager
+}
+
+const BackendMemory = "memory"
+
+// New builds the repositories for a storage backend. The memory backend
+// ignores db and opts; every other backend name is parsed as a SQL dialect.
+func New(backend string, db *sql.DB, opts Options) (*Repositories, error) {
+	if backend == BackendMemory {
+		tasks := NewMemoryTaskRepository()
+		return &Repositories{
+			Tasks:           tasks,
+			Users:           NewMemoryUserRepository(tasks),
+			APIKeys:         NewMemoryAPIKeyRepository(),
+			Sessions:        NewMemorySessionRepository(),
+			IdempotencyKeys: NewMemoryIdempotencyRepository(),
+			Audit:           NewMemoryAuditRepository(),
+			// API keys and idempotency keys are never written inside a
+			// transaction, so their writes are not logged for rollback.
+			Tx: newMemoryTxManager(),
+		}, nil
+	}
+
+	dialect, err := ParseDialect(backend)
+	if err != nil {
+		return nil, err
+	}
+	if db == nil {
+		return nil, fmt.Errorf("storage backend %q needs a database connection", backend)
+	}
+
+	return &Repositories{
+		Tasks:           NewTaskRepository(db, dialect, opts),
+		Users:           NewUserRepository(db, dialect, opts),
+		APIKeys:         NewAPIKeyRepository(db, dialect, opts),
+		Sessions:        NewSessionRepository(db, dialect, opts),
+		IdempotencyKeys: NewIdempotencyRepository(db, dialect, opts),
+		Audit:           NewAuditRepository(db, dialect, opts),
+		Tx:              NewTxManager(db, dialect, opts),
+	}, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"taskmanager/models"
+	"time"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+	RevokeUserSessions(ctx context.Context, userID int, at time.Time) (int, error)
+	PurgeExpiredSessions(ctx context.Context, now time.Time) (int, error)
+}
+
+type sessionRepository struct {
+	sqlStore
+}
+
+func NewSessionRepository(db *sql.DB, dialect Dialect, opts Options) SessionRepository {
+	return &sessionRepository{sqlStore: newSQLStore(db, dialect, opts)}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	query := "INSERT INTO sessions (id, user_id, created_at, expires_at, revoked_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := r.exec(ctx, query,
+		session.ID, session.UserID, session.CreatedAt, session.ExpiresAt, session.RevokedAt)
+	return translateError(err)
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	query := "SELECT id, user_id, created_at, expires_at, revoked_at FROM sessions WHERE id = ?"
+
+	var session models.Session
+	var revokedAt sql.NullTime
+	err := r.queryRow(ctx, query, id).Scan(
+		&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+// RevokeSession ends a session. Revoking one that has already ended, or
+// does not exist, is not an error.
+func (r *sessionRepository) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	query := "UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	_, err := r.exec(ctx, query, at, id)
+	return err
+}
+
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID int, at time.Time) (int, error) {
+	query := "UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL"
+	result, err := r.exec(ctx, query, at, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// PurgeExpiredSessions deletes the sessions that expired at or before now,
+// whether or not they were revoked first.
+func (r *sessionRepository) PurgeExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+	result, err := r.exec(ctx, "DELETE FROM sessions WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"taskmanager/tracing"
+	"time"
+)
+
+// Options configure the SQL repositories.
+type Options struct {
+	Logger *slog.Logger
+	// SlowQuery is the duration above which a statement is logged as a
+	// warning. Zero disables slow-query logging.
+	SlowQuery time.Duration
+	// Tracer, if set, records a client span for every statement.
+	Tracer *tracing.Tracer
+	// TxRetries is how many times a transaction the database aborted to
+	// break a deadlock is run again. Zero disables retries.
+	TxRetries int
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlStore runs the SQL repositories' statements for their dialect, in the
+// transaction carried by ctx if any.
+type sqlStore struct {
+	db        *sql.DB
+	dialect   Dialect
+	logger    *slog.Logger
+	slowQuery time.Duration
+	tracer    *tracing.Tracer
+	tx        *txManager
+}
+
+func newSQLStore(db *sql.DB, dialect Dialect, opts Options) sqlStore {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return sqlStore{db: db, dialect: dialect, logger: logger, slowQuery: opts.SlowQuery, tracer: opts.Tracer,
+		tx: newTxManager(db, dialect, opts)}
+}
+
+// conn returns the transaction ctx carries for this store's database, or
+// the database itself.
+func (s sqlStore) conn(ctx context.Context) querier {
+	if t, ok := ctx.Value(txKey{}).(*boundTx); ok && t.db == s.db {
+		return t.tx
+	}
+	return s.db
+}
+
+// savepoint runs fn inside a named savepoint of the transaction ctx
+// carries, so a failing fn only undoes its own statements. err reports a
+// failure to manage the savepoint and wraps fnErr.
+func (s sqlStore) savepoint(ctx context.Context, name string, fn func() error) (fnErr, err error) {
+	if _, err := s.exec(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	if fnErr := fn(); fnErr != nil {
+		if _, err := s.exec(ctx, "ROLLBACK TO SAVEPOINT "+name); err != nil {
+			return fnErr, errors.Join(fnErr, err)
+		}
+		return fnErr, nil
+	}
+	_, err = s.exec(ctx, "RELEASE SAVEPOINT "+name)
+	return nil, err
+}
+
+func (s sqlStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := s.observe(ctx, query)
+	result, err := s.conn(ctx).ExecContext(ctx, s.dialect.Rebind(query), args...)
+	done(err)
+	return result, err
+}
+
+// query's span covers running the statement, not reading the rows.
+func (s sqlStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := s.observe(ctx, query)
+	rows, err := s.conn(ctx).QueryContext(ctx, s.dialect.Rebind(query), args...)
+	done(err)
+	return rows, err
+}
+
+func (s sqlStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := s.observe(ctx, query)
+	row := s.conn(ctx).QueryRowContext(ctx, s.dialect.Rebind(query), args...)
+	done(row.Err())
+	return row
+}
+
+// insert runs an INSERT and returns the generated id. PostgreSQL drivers do
+// not implement LastInsertId, so the id is read back with RETURNING instead.
+func (s sqlStore) insert(ctx context.Context, query string, args ...any) (int, error) {
+	if s.dialect == DialectPostgres {
+		var id int
+		err := s.queryRow(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// observe traces the statement and logs it if it is slow. Only the
+// sanitized statement is recorded, since arguments can hold personal data.
+func (s sqlStore) observe(ctx context.Context, query string) (context.Context, func(error)) {
+	start := time.Now()
+	statement := sanitizeSQL(query)
+	verb, _, _ := strings.Cut(statement, " ")
+	ctx, span := s.tracer.Start(ctx, strings.ToUpper(verb), tracing.KindClient,
+		slog.String("db.system", string(s.dialect)),
+		slog.String("db.statement", statement))
+
+	return ctx, func(err error) {
+		span.Finish(err)
+		if s.slowQuery <= 0 {
+			return
+		}
+		if elapsed := time.Since(start); elapsed > s.slowQuery {
+			s.logger.WarnContext(ctx, "slow query",
+				slog.String("query", statement),
+				slog.Duration("duration", elapsed),
+				slog.Duration("threshold", s.slowQuery))
+		}
+	}
+}
+
+// sanitizeSQL collapses whitespace and replaces string and number literals
+// with "?", leaving the shape of the statement.
+func sanitizeSQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = b.Len() > 0
+			continue
+		case c == '\'':
+			// Skip to the closing quote; '' is an escaped quote.
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			c = '?'
+		case c >= '0' && c <= '9' && !identifierByte(query, i-1):
+			for i+1 < len(query) && (query[i+1] >= '0' && query[i+1] <= '9' || query[i+1] == '.') {
+				i++
+			}
+			c = '?'
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func identifierByte(s string, i int) bool {
+	if i < 0 {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Conditions on deleted_at. Rows in the trash are out of reach of every
+// write but a restore.
+const (
+	live    = "deleted_at IS NULL"
+	trashed = "deleted_at IS NOT NULL"
+)
+
+// checkVersioned reports why a write made conditional on a row's version
+// matched no row, scope being live or trashed.
+func (s sqlStore) checkVersioned(ctx context.Context, result sql.Result, table, scope string, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists int
+	err = s.queryRow(ctx, "SELECT 1 FROM "+table+" WHERE id = ? AND "+scope, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"taskmanager/tracing"
+	"testing"
+	"time"
+)
+
+func TestSlowQueriesAreLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	query := "SELECT id\n\t\tFROM tasks WHERE user_id = ?"
+
+	fast := newSQLStore(nil, DialectMySQL, Options{Logger: logger, SlowQuery: time.Hour})
+	_, done := fast.observe(context.Background(), query)
+	done(nil)
+	if buf.Len() != 0 {
+		t.Errorf("Expected a fast query not to be logged, got %s", buf.String())
+	}
+
+	slow := newSQLStore(nil, DialectMySQL, Options{Logger: logger, SlowQuery: time.Nanosecond})
+	_, done = slow.observe(context.Background(), query)
+	time.Sleep(time.Millisecond)
+	done(nil)
+	if !strings.Contains(buf.String(), `msg="slow query"`) || !strings.Contains(buf.String(), `query="SELECT id FROM tasks WHERE user_id = ?"`) {
+		t.Errorf("Expected a slow query warning, got %s", buf.String())
+	}
+}
+
+func TestStatementsAreTraced(t *testing.T) {
+	rec := &tracing.Recorder{}
+	tracer := tracing.NewTracer(rec)
+	store := newSQLStore(nil, DialectPostgres, Options{Tracer: tracer})
+
+	ctx, parent := tracer.Start(context.Background(), "tasks.ListTasks", tracing.KindInternal)
+	_, done := store.observe(ctx, "select id FROM users\n WHERE email = 'a@example.com' LIMIT 10")
+	done(errors.New("connection reset"))
+	parent.End()
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", spans)
+	}
+	span := spans[0]
+	if span.Name != "SELECT" || span.Kind != tracing.KindClient || span.ParentSpanID != spans[1].SpanID {
+		t.Errorf("Unexpected span %+v", span)
+	}
+	if span.Attributes["db.statement"] != "select id FROM users WHERE email = ? LIMIT ?" || span.Attributes["db.system"] != "postgres" {
+		t.Errorf("Unexpected attributes %v", span.Attributes)
+	}
+	if span.Status != "error" {
+		t.Errorf("Expected the error to be recorded, got %+v", span)
+	}
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"SELECT id FROM tasks WHERE user_id = ?", "SELECT id FROM tasks WHERE user_id = ?"},
+		{"  UPDATE users\n\tSET name = 'O''Brien'  WHERE id = 42 ", "UPDATE users SET name = ? WHERE id = ?"},
+		{"SELECT t1.id FROM tasks t1 WHERE priority >= 2.5", "SELECT t1.id FROM tasks t1 WHERE priority >= ?"},
+		{"SELECT * FROM x WHERE a = $1", "SELECT * FROM x WHERE a = $1"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeSQL(tt.in); got != tt.want {
+			t.Errorf("sanitizeSQL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
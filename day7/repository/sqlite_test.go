@@ -0,0 +1,53 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"taskmanager/config"
+	"taskmanager/migrations"
+	"taskmanager/models"
+	"taskmanager/repository"
+	"testing"
+)
+
+// TestSQLiteBackend runs the suite against the SQL repositories on a fresh
+// SQLite database per test, migrated like a real deployment, so they are
+// covered without a database server.
+func TestSQLiteBackend(t *testing.T) {
+	repository.RunConformance(t, newSQLite)
+}
+
+// TestSQLiteEnforcesForeignKeys checks that ConnectDB turns on the foreign
+// keys SQLite leaves off, which the memory backend has no equivalent of.
+func TestSQLiteEnforcesForeignKeys(t *testing.T) {
+	repos := newSQLite(t)
+	err := repos.Tasks.CreateTask(context.Background(), &models.Task{Title: "Orphan", UserId: 404,
+		Status: models.StatusOpen, Priority: models.PriorityLow})
+	if !errors.Is(err, repository.ErrForeignKey) {
+		t.Errorf("Expected ErrForeignKey, got %v", err)
+	}
+}
+
+func newSQLite(t *testing.T) *repository.Repositories {
+	db, err := config.ConnectDB(repository.DialectSQLite.DriverName(),
+		config.DBConfig{DSN: config.Secret(filepath.Join(t.TempDir(), "taskmanager.db"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, repository.DialectSQLite, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(0); err != nil {
+		t.Fatalf("migrating failed: %v", err)
+	}
+
+	repos, err := repository.New(string(repository.DialectSQLite), db, repository.Options{TxRetries: 3})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return repos
+}
@@ -1,60 +1,538 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"taskmanager/models"
+	"time"
 )
 
+// TaskRepository.DeleteTask moves tasks to the trash, which hides them from
+// every method but the ones named for deleted tasks.
 type TaskRepository interface {
-	CreateTask(task *models.Task) error
-	GetTasksByUserID(id int) ([]models.Task, error)
+	CreateTask(ctx context.Context, task *models.Task) error
+	GetTaskByID(ctx context.Context, id int) (*models.Task, error)
+	GetTasksByUserID(ctx context.Context, id int) ([]models.Task, error)
+	ListTasks(ctx context.Context, q models.TaskQuery) ([]models.Task, *models.TaskCursor, error)
+	CountTasksByUserID(ctx context.Context, userID int) (int, error)
+	// UpdateTask writes the task if it is still at task.Version, which it
+	// then increments, and fails with ErrVersionConflict otherwise.
+	UpdateTask(ctx context.Context, task *models.Task) error
+	// DeleteTask moves the task to the trash if it is still at version.
+	DeleteTask(ctx context.Context, id, version int, at time.Time) error
+	DeleteTasksByUserID(ctx context.Context, userID int, at time.Time) (int, error)
+	ReassignTasks(ctx context.Context, fromUserID, toUserID int) (int, error)
+	GetDeletedTask(ctx context.Context, id int) (*models.Task, error)
+	// ListDeletedTasks returns a user's tasks in the trash, most recently
+	// deleted first.
+	ListDeletedTasks(ctx context.Context, userID int) ([]models.Task, error)
+	// RestoreTask takes the task out of the trash if it is still at version.
+	RestoreTask(ctx context.Context, id, version int) error
+	// PurgeDeletedTasks removes the tasks deleted before before for good.
+	PurgeDeletedTasks(ctx context.Context, before time.Time) (int, error)
+	// CreateTasks inserts tasks, all or none, and sets their ids.
+	CreateTasks(ctx context.Context, tasks []*models.Task) error
+	// ApplyBatch runs ops in order and returns an error for each op that
+	// failed. In atomic mode the first failure undoes the whole batch. A
+	// non-nil err means nothing was written.
+	ApplyBatch(ctx context.Context, ops []TaskOp, atomic bool) (errs []error, err error)
+}
+
+// TaskOp is one write in a batch. Deletes only read Task.ID, Task.Version
+// and Task.DeletedAt.
+type TaskOp struct {
+	Action models.BatchAction
+	Task   *models.Task
 }
 
 type taskRepository struct {
-	db *sql.DB
+	sqlStore
+
+	// idStep caches mysqlIDStep once idStepKnown is set.
+	mu          sync.Mutex
+	idStep      int
+	idStepKnown bool
+}
+
+func NewTaskRepository(db *sql.DB, dialect Dialect, opts Options) TaskRepository {
+	return &taskRepository{sqlStore: newSQLStore(db, dialect, opts)}
+}
+
+const taskColumns = "id,title,description,user_id,status,priority,due_date,created_at,updated_at,completed_at,version,deleted_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (*models.Task, error) {
+	var task models.Task
+	var dueDate, completedAt, deletedAt sql.NullTime
+
+	err := row.Scan(&task.ID, &task.Title, &task.Description, &task.UserId, &task.Status, &task.Priority,
+		&dueDate, &task.CreatedAt, &task.UpdatedAt, &completedAt, &task.Version, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if dueDate.Valid {
+		task.DueDate = &dueDate.Time
+	}
+	if completedAt.Valid {
+		task.CompletedAt = &completedAt.Time
+	}
+	if deletedAt.Valid {
+		task.DeletedAt = &deletedAt.Time
+	}
+	return &task, nil
+}
+
+func (r *taskRepository) CreateTask(ctx context.Context, task *models.Task) error {
+	query := `INSERT INTO tasks (title, description, user_id, status, priority, due_date, created_at, updated_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	id, err := r.insert(ctx, query, task.Title, task.Description, task.UserId, task.Status, task.Priority,
+		task.DueDate, task.CreatedAt, task.UpdatedAt, task.CompletedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	task.ID = id
+	task.Version = 1
+	return nil
+}
+
+func (r *taskRepository) GetTaskByID(ctx context.Context, id int) (*models.Task, error) {
+	return r.getTask(ctx, id, live)
+}
+
+func (r *taskRepository) GetDeletedTask(ctx context.Context, id int) (*models.Task, error) {
+	return r.getTask(ctx, id, trashed)
+}
+
+func (r *taskRepository) getTask(ctx context.Context, id int, scope string) (*models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE id=? AND " + scope
+
+	task, err := scanTask(r.queryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+func (r *taskRepository) GetTasksByUserID(ctx context.Context, id int) ([]models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks where user_id=? AND " + live + " ORDER BY id"
+	return r.queryTasks(ctx, query, id)
+}
+
+func (r *taskRepository) ListDeletedTasks(ctx context.Context, userID int) ([]models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE user_id=? AND " + trashed + " ORDER BY deleted_at DESC, id"
+	return r.queryTasks(ctx, query, userID)
+}
+
+func (r *taskRepository) queryTasks(ctx context.Context, query string, args ...any) ([]models.Task, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (r *taskRepository) ListTasks(ctx context.Context, q models.TaskQuery) ([]models.Task, *models.TaskCursor, error) {
+	key, err := sortKeyFor(q.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where := []string{"user_id = ?", live}
+	args := []any{q.UserID}
+
+	if len(q.Statuses) > 0 {
+		where = append(where, "status IN ("+placeholders(len(q.Statuses))+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+	if len(q.Priorities) > 0 {
+		where = append(where, "priority IN ("+placeholders(len(q.Priorities))+")")
+		for _, priority := range q.Priorities {
+			args = append(args, priority)
+		}
+	}
+	if q.DueBefore != nil {
+		where = append(where, "due_date < ?")
+		args = append(args, *q.DueBefore)
+	}
+	if q.DueAfter != nil {
+		where = append(where, "due_date > ?")
+		args = append(args, *q.DueAfter)
+	}
+	if q.Search != "" {
+		where = append(where, "LOWER(title) LIKE ? ESCAPE '!'")
+		args = append(args, likePattern(q.Search))
+	}
+
+	dir, op := "ASC", ">"
+	if q.Sort.Desc {
+		dir, op = "DESC", "<"
+	}
+
+	if q.After != nil {
+		value, err := cursorValue(key, q.After)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key.expr == "id" {
+			where = append(where, "id "+op+" ?")
+			args = append(args, q.After.ID)
+		} else {
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", key.expr, op))
+			args = append(args, value, value, q.After.ID)
+		}
+	}
+
+	query := "SELECT " + taskColumns + " FROM tasks WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY " + key.expr + " " + dir
+	if key.expr != "id" {
+		query += ", id " + dir
+	}
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	tasks, next := pageOf(key, tasks, q.Limit)
+	return tasks, next, nil
+}
+
+func (r *taskRepository) CountTasksByUserID(ctx context.Context, userID int) (int, error) {
+	query := "SELECT COUNT(*) FROM tasks WHERE user_id=? AND " + live
+
+	var count int
+	err := r.queryRow(ctx, query, userID).Scan(&count)
+	return count, err
 }
 
-func NewTaskRepository(db *sql.DB) TaskRepository {
-	return &taskRepository{
-		db: db,
+func (r *taskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
+	query := `UPDATE tasks SET title=?, description=?, user_id=?, status=?, priority=?, due_date=?, updated_at=?, completed_at=?,
+		version=version+1 WHERE id=? AND version=? AND ` + live
+	result, err := r.exec(ctx, query, task.Title, task.Description, task.UserId, task.Status, task.Priority,
+		task.DueDate, task.UpdatedAt, task.CompletedAt, task.ID, task.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	if err := r.checkVersioned(ctx, result, "tasks", live, task.ID); err != nil {
+		return err
 	}
+
+	task.Version++
+	return nil
 }
 
-func (r *taskRepository) CreateTask(task *models.Task) error {
-	query := "INSERT INTO tasks (title, description, user_id) VALUES (?, ?, ?)"
-	data, err := r.db.Exec(query, task.Title, task.Description, task.UserId)
+func (r *taskRepository) DeleteTask(ctx context.Context, id, version int, at time.Time) error {
+	query := "UPDATE tasks SET deleted_at=?, version=version+1 WHERE id=? AND version=? AND " + live
+	result, err := r.exec(ctx, query, at, id, version)
 	if err != nil {
 		return err
 	}
+	return r.checkVersioned(ctx, result, "tasks", live, id)
+}
+
+func (r *taskRepository) DeleteTasksByUserID(ctx context.Context, userID int, at time.Time) (int, error) {
+	query := "UPDATE tasks SET deleted_at=?, version=version+1 WHERE user_id=? AND " + live
+	result, err := r.exec(ctx, query, at, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// ReassignTasks leaves the tasks in the trash with their owner.
+func (r *taskRepository) ReassignTasks(ctx context.Context, fromUserID, toUserID int) (int, error) {
+	query := "UPDATE tasks SET user_id=?, version=version+1 WHERE user_id=? AND " + live
+	result, err := r.exec(ctx, query, toUserID, fromUserID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
 
-	id, err := data.LastInsertId()
+func (r *taskRepository) RestoreTask(ctx context.Context, id, version int) error {
+	query := "UPDATE tasks SET deleted_at=NULL, version=version+1 WHERE id=? AND version=? AND " + trashed
+	result, err := r.exec(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
+	return r.checkVersioned(ctx, result, "tasks", trashed, id)
+}
+
+func (r *taskRepository) PurgeDeletedTasks(ctx context.Context, before time.Time) (int, error) {
+	query := "DELETE FROM tasks WHERE deleted_at < ?"
+	result, err := r.exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
 
-	task.ID = int(id)
+// insertChunk bounds the rows in one multi-row INSERT, keeping statements
+// well below every dialect's placeholder limit.
+const insertChunk = 500
+
+func (r *taskRepository) CreateTasks(ctx context.Context, tasks []*models.Task) error {
+	return r.tx.Run(ctx, func(ctx context.Context) error {
+		for chunk := range slices.Chunk(tasks, insertChunk) {
+			if err := r.insertTasks(ctx, chunk); err != nil {
+				return translateError(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *taskRepository) insertTasks(ctx context.Context, tasks []*models.Task) error {
+	row := "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	insert := "INSERT INTO tasks (title, description, user_id, status, priority, due_date, created_at, updated_at, completed_at) VALUES "
+	query := insert + strings.Repeat(row+", ", len(tasks)-1) + row
+	args := make([]any, 0, 9*len(tasks))
+	for _, task := range tasks {
+		args = append(args, task.Title, task.Description, task.UserId, task.Status, task.Priority,
+			task.DueDate, task.CreatedAt, task.UpdatedAt, task.CompletedAt)
+	}
+
+	var ids []int
+	var err error
+	if r.dialect == DialectMySQL {
+		ids, err = r.insertMySQL(ctx, insert+row, query, args)
+	} else {
+		ids, err = r.insertReturning(ctx, query+" RETURNING id", args)
+	}
+	if err != nil {
+		return err
+	}
+	if len(ids) != len(tasks) {
+		return errors.New("INSERT returned fewer ids than rows")
+	}
+	for i, task := range tasks {
+		task.ID = ids[i]
+		task.Version = 1
+	}
 	return nil
 }
 
-func (r *taskRepository) GetTasksByUserID(id int) ([]models.Task, error) {
-	query := "SELECT id,title,description,user_id FROM tasks where user_id=?"
+// insertMySQL runs the multi-row query and derives the ids from the first
+// one, since MySQL has no RETURNING. When they may not be evenly spaced it
+// inserts one row at a time instead.
+func (r *taskRepository) insertMySQL(ctx context.Context, single, multi string, args []any) ([]int, error) {
+	n := len(args) / 9
+	step, err := r.mysqlIDStep(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, n)
+	if step == 0 {
+		for i := range n {
+			id, err := r.insert(ctx, single, args[9*i:9*i+9]...)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
 
-	rows, err := r.db.Query(query, id)
+	result, err := r.exec(ctx, multi, args...)
+	if err != nil {
+		return nil, err
+	}
+	first, err := result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
+	for i := range n {
+		ids = append(ids, int(first)+i*step)
+	}
+	return ids, nil
+}
+
+// mysqlIDStep returns the gap between the ids InnoDB gives the rows of one
+// multi-row INSERT. They are only evenly spaced when innodb_autoinc_lock_mode
+// is 0 or 1; under 2 concurrent inserts may interleave, and it returns 0.
+func (r *taskRepository) mysqlIDStep(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.idStepKnown {
+		return r.idStep, nil
+	}
+
+	var mode, step int
+	if err := r.queryRow(ctx, "SELECT @@innodb_autoinc_lock_mode, @@auto_increment_increment").Scan(&mode, &step); err != nil {
+		return 0, err
+	}
+	if mode == 2 {
+		r.logger.WarnContext(ctx, "innodb_autoinc_lock_mode is 2, so tasks are created one row at a time")
+		step = 0
+	}
+	r.idStep, r.idStepKnown = step, true
+	return step, nil
+}
 
+// insertReturning runs query and returns the ids it reports in row order.
+// RETURNING does not promise an order, but PostgreSQL and SQLite both insert
+// the VALUES rows in order, so their ids increase with it. The
+// CreateTasksKeepsRowOrder conformance test checks this on every dialect.
+func (r *taskRepository) insertReturning(ctx context.Context, query string, args []any) ([]int, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var tasks []models.Task
+	var ids []int
 	for rows.Next() {
-		var task models.Task
-		err = rows.Scan(&task.ID, &task.Title, &task.Description, &task.UserId)
-		if err != nil {
+		var id int
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, task)
+		ids = append(ids, id)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
 
-	return tasks, nil
+// ApplyBatch inserts each run of consecutive creates with one CreateTasks,
+// retrying it row by row on failure. An atomic batch runs in a savepoint.
+func (r *taskRepository) ApplyBatch(ctx context.Context, ops []TaskOp, atomic bool) ([]error, error) {
+	errs := make([]error, len(ops))
+	versions := make([]int, len(ops))
+	for i, op := range ops {
+		versions[i] = op.Task.Version
+	}
+	err := r.tx.Run(ctx, func(ctx context.Context) error {
+		// A retried transaction starts over from the versions the caller
+		// read, not the ones the failed attempt set.
+		clear(errs)
+		for i, op := range ops {
+			op.Task.Version = versions[i]
+		}
+		if !atomic {
+			return r.applyOps(ctx, ops, errs, false)
+		}
+		failed, err := r.savepoint(ctx, "batch", func() error { return r.applyOps(ctx, ops, errs, true) })
+		if err == nil && failed != nil && !errors.Is(failed, errBatchFailed) {
+			err = failed
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// errBatchFailed stops an atomic batch at its first failing op.
+var errBatchFailed = errors.New("batch operation failed")
+
+// applyOps records the error of each failed op in errs. It returns an error
+// when the transaction is unusable, or errBatchFailed to stop an atomic batch.
+func (r *taskRepository) applyOps(ctx context.Context, ops []TaskOp, errs []error, atomic bool) error {
+	for i := 0; i < len(ops); {
+		n := 1
+		for i+n < len(ops) && ops[i].Action == models.BatchCreate && ops[i+n].Action == models.BatchCreate {
+			n++
+		}
+
+		if n > 1 {
+			tasks := make([]*models.Task, n)
+			for j, op := range ops[i : i+n] {
+				tasks[j] = op.Task
+			}
+			failed, err := r.savepoint(ctx, "batch_item", func() error { return r.CreateTasks(ctx, tasks) })
+			if err != nil {
+				return err
+			}
+			if failed == nil {
+				i += n
+				continue
+			}
+		}
+
+		for j := i; j < i+n; j++ {
+			var failed error
+			if atomic {
+				failed = r.applyOp(ctx, ops[j])
+			} else {
+				var err error
+				if failed, err = r.savepoint(ctx, "batch_item", func() error { return r.applyOp(ctx, ops[j]) }); err != nil {
+					return err
+				}
+			}
+			if failed != nil {
+				errs[j] = failed
+				if atomic {
+					return errBatchFailed
+				}
+			}
+		}
+		i += n
+	}
+	return nil
+}
+
+func (r *taskRepository) applyOp(ctx context.Context, op TaskOp) error {
+	switch op.Action {
+	case models.BatchCreate:
+		return r.CreateTask(ctx, op.Task)
+	case models.BatchUpdate:
+		return r.UpdateTask(ctx, op.Task)
+	case models.BatchDelete:
+		return r.DeleteTask(ctx, op.Task.ID, op.Task.Version, *op.Task.DeletedAt)
+	}
+	return fmt.Errorf("unknown batch action %q", op.Action)
 }
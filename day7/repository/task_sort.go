@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"taskmanager/models"
+	"time"
+)
+
+// Tasks without a due date sort after every dated task.
+var noDueDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+const (
+	noDueDateSQL = "'9999-12-31 00:00:00'"
+	priorityRank = "CASE priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 WHEN 'high' THEN 3 WHEN 'urgent' THEN 4 ELSE 0 END"
+)
+
+// taskSortKey describes how one sort field is ordered, both in SQL and in
+// memory, so every backend pages through results the same way.
+type taskSortKey struct {
+	expr string
+	key  func(task *models.Task) any
+}
+
+var taskSortKeys = map[string]taskSortKey{
+	models.SortByID: {
+		expr: "id",
+		key:  func(task *models.Task) any { return task.ID },
+	},
+	models.SortByCreatedAt: {
+		expr: "created_at",
+		key:  func(task *models.Task) any { return task.CreatedAt.UTC() },
+	},
+	models.SortByUpdatedAt: {
+		expr: "updated_at",
+		key:  func(task *models.Task) any { return task.UpdatedAt.UTC() },
+	},
+	models.SortByDueDate: {
+		expr: "COALESCE(due_date, " + noDueDateSQL + ")",
+		key: func(task *models.Task) any {
+			if task.DueDate == nil {
+				return noDueDate
+			}
+			return task.DueDate.UTC()
+		},
+	},
+	models.SortByPriority: {
+		expr: priorityRank,
+		key:  func(task *models.Task) any { return task.Priority.Rank() },
+	},
+	models.SortByTitle: {
+		expr: "title",
+		key:  func(task *models.Task) any { return task.Title },
+	},
+}
+
+func sortKeyFor(sort models.TaskSort) (taskSortKey, error) {
+	key, ok := taskSortKeys[sort.Field]
+	if !ok {
+		return taskSortKey{}, fmt.Errorf("unsupported sort field %q", sort.Field)
+	}
+	return key, nil
+}
+
+func cursorFor(key taskSortKey, task *models.Task) *models.TaskCursor {
+	var value string
+	switch v := key.key(task).(type) {
+	case int:
+		value = strconv.Itoa(v)
+	case time.Time:
+		value = v.Format(time.RFC3339Nano)
+	case string:
+		value = v
+	}
+	return &models.TaskCursor{Value: value, ID: task.ID}
+}
+
+// cursorValue parses a cursor value back into the type key produces.
+func cursorValue(key taskSortKey, cursor *models.TaskCursor) (any, error) {
+	switch key.key(&models.Task{}).(type) {
+	case int:
+		return strconv.Atoi(cursor.Value)
+	case time.Time:
+		return time.Parse(time.RFC3339Nano, cursor.Value)
+	}
+	return cursor.Value, nil
+}
+
+func compareKeys(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// likePattern builds a case-insensitive LIKE pattern matching text anywhere,
+// escaping wildcards with '!' since the default escape differs by database.
+func likePattern(text string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return "%" + strings.ToLower(r.Replace(text)) + "%"
+}
+
+// pageOf trims a result fetched with limit+1 rows and returns the cursor of
+// the last kept task when more rows remain.
+func pageOf(key taskSortKey, tasks []models.Task, limit int) ([]models.Task, *models.TaskCursor) {
+	if limit <= 0 || len(tasks) <= limit {
+		return tasks, nil
+	}
+	tasks = tasks[:limit]
+	return tasks, cursorFor(key, &tasks[limit-1])
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
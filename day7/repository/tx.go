@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"taskmanager/tracing"
+	"time"
+)
+
+// TxManager runs units of work. Every repository call made with the ctx
+// passed to fn runs in one transaction, which commits when fn returns nil
+// and rolls back when it returns an error or panics. Calling Run with a ctx
+// that already carries a transaction joins it, so the outermost Run decides
+// the outcome.
+//
+// A transaction the database aborted to break a deadlock is run again, so
+// fn must not have side effects outside the repositories.
+type TxManager interface {
+	Run(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// boundTx is the transaction carried by a ctx, with the database it belongs
+// to so a store for another database does not pick it up.
+type boundTx struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+type txManager struct {
+	db      *sql.DB
+	dialect Dialect
+	retries int
+	logger  *slog.Logger
+	tracer  *tracing.Tracer
+}
+
+// NewTxManager returns the TxManager for a SQL database.
+func NewTxManager(db *sql.DB, dialect Dialect, opts Options) TxManager {
+	return newTxManager(db, dialect, opts)
+}
+
+func newTxManager(db *sql.DB, dialect Dialect, opts Options) *txManager {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &txManager{db: db, dialect: dialect, retries: opts.TxRetries, logger: logger, tracer: opts.Tracer}
+}
+
+func (m *txManager) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if t, ok := ctx.Value(txKey{}).(*boundTx); ok && t.db == m.db {
+		return fn(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.runOnce(ctx, fn, attempt)
+		if err == nil || attempt > m.retries || !retryable(err) {
+			return err
+		}
+
+		backoff := time.Duration(attempt)*10*time.Millisecond + rand.N(10*time.Millisecond)
+		m.logger.WarnContext(ctx, "retrying transaction",
+			slog.Int("attempt", attempt), slog.Duration("backoff", backoff), slog.Any("error", err))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (m *txManager) runOnce(ctx context.Context, fn func(ctx context.Context) error, attempt int) (err error) {
+	ctx, span := m.tracer.Start(ctx, "TRANSACTION", tracing.KindClient,
+		slog.String("db.system", string(m.dialect)), slog.Int("db.transaction.attempt", attempt))
+	defer func() { span.Finish(err) }()
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, &boundTx{db: m.db, tx: tx})); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// memoryTxManager serializes memory transactions and rolls a failed one
+// back by replaying its undo log. A write made outside Run to a record the
+// failed transaction also wrote is overwritten by the rollback.
+type memoryTxManager struct {
+	mu sync.Mutex
+}
+
+type memoryTxKey struct{}
+
+// memoryTx is the undo log of a running memory transaction.
+type memoryTx struct {
+	m    *memoryTxManager
+	undo []func()
+}
+
+func newMemoryTxManager() *memoryTxManager {
+	return &memoryTxManager{}
+}
+
+func (m *memoryTxManager) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok && tx.m == m {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memoryTx{m: m}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, tx)); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+func (tx *memoryTx) rollback() {
+	for _, undo := range slices.Backward(tx.undo) {
+		undo()
+	}
+}
+
+// logUndo adds undo to the log of the memory transaction ctx carries, if
+// any. undo puts back what a write replaced and runs with mu locked.
+func logUndo(ctx context.Context, mu sync.Locker, undo func()) {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			undo()
+		})
+	}
+}
+
+// putEntry sets m[key] and returns the func that puts back the entry it
+// replaced, or removes the key if there was none.
+func putEntry[K comparable, V any](m map[K]V, key K, value V) (undo func()) {
+	old, ok := m[key]
+	m[key] = value
+	return restoreEntry(m, key, old, ok)
+}
+
+// deleteEntry removes m[key] and returns the func that puts it back.
+func deleteEntry[K comparable, V any](m map[K]V, key K) (undo func()) {
+	old, ok := m[key]
+	delete(m, key)
+	return restoreEntry(m, key, old, ok)
+}
+
+func restoreEntry[K comparable, V any](m map[K]V, key K, old V, ok bool) func() {
+	return func() {
+		if ok {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"taskmanager/models"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestMemoryTxRollsBack(t *testing.T) {
+	repos, err := New(BackendMemory, nil, Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	count := func() int {
+		n, _ := repos.Tasks.CountTasksByUserID(ctx, 1)
+		return n
+	}
+	create := func(ctx context.Context) error {
+		return repos.Tasks.CreateTask(ctx, &models.Task{Title: "Onboarding", UserId: 1})
+	}
+
+	failure := errors.New("second step failed")
+	err = repos.Tx.Run(ctx, func(ctx context.Context) error {
+		if err := create(ctx); err != nil {
+			return err
+		}
+		// A nested unit of work joins the outer one.
+		if err := repos.Tx.Run(ctx, create); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) || count() != 0 {
+		t.Errorf("Expected the failed unit of work to leave nothing, got %v with %d tasks", err, count())
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to propagate")
+			}
+		}()
+		repos.Tx.Run(ctx, func(ctx context.Context) error {
+			create(ctx)
+			panic("boom")
+		})
+	}()
+	if count() != 0 {
+		t.Errorf("Expected a panic to roll back, got %d tasks", count())
+	}
+
+	if err := repos.Tx.Run(ctx, func(ctx context.Context) error { return errors.Join(create(ctx), create(ctx)) }); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if count() != 2 {
+		t.Errorf("Expected 2 committed tasks, got %d", count())
+	}
+
+	tasks, _ := repos.Tasks.GetTasksByUserID(ctx, 1)
+	before := &tasks[0]
+	err = repos.Tx.Run(ctx, func(ctx context.Context) error {
+		task := *before
+		task.Title = "Renamed"
+		if err := repos.Tasks.UpdateTask(ctx, &task); err != nil {
+			return err
+		}
+		if err := repos.Tasks.DeleteTask(ctx, task.ID, task.Version, time.Now()); err != nil {
+			return err
+		}
+		return failure
+	})
+	if after, _ := repos.Tasks.GetTaskByID(ctx, before.ID); !errors.Is(err, failure) || after == nil || *after != *before {
+		t.Errorf("Expected the rollback to restore %+v, got %+v (%v)", before, after, err)
+	}
+}
+
+// TestMemoryTxKeepsOtherWrites checks that a rollback only undoes the
+// failed transaction: a write through Run waits for it, and API key writes
+// are not logged.
+func TestMemoryTxKeepsOtherWrites(t *testing.T) {
+	repos, err := New(BackendMemory, nil, Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	repos.Sessions.CreateSession(ctx, &models.Session{ID: "s1", UserID: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	failure := errors.New("failed")
+	done := make(chan error, 1)
+	err = repos.Tx.Run(ctx, func(ctx context.Context) error {
+		go func() {
+			done <- repos.Tx.Run(context.Background(), func(ctx context.Context) error {
+				return repos.Sessions.RevokeSession(ctx, "s1", now)
+			})
+		}()
+		if err := repos.APIKeys.CreateAPIKey(context.Background(), &models.APIKey{UserID: 1, Hash: "h", CreatedAt: now}); err != nil {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the transaction to fail, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+
+	session, _ := repos.Sessions.GetSession(ctx, "s1")
+	if session == nil || session.RevokedAt == nil {
+		t.Errorf("Expected the session to stay revoked, got %+v", session)
+	}
+	if key, _ := repos.APIKeys.GetAPIKeyByHash(ctx, "h"); key == nil {
+		t.Error("Expected the API key to survive the rollback")
+	}
+}
+
+// fakeDriver opens connections that only begin, commit and roll back, and
+// fails the first commitFailures commits with commitErr.
+type fakeDriver struct {
+	commitErr      error
+	commitFailures int32
+	begins         atomic.Int32
+	commits        atomic.Int32
+	rollbacks      atomic.Int32
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	c.d.begins.Add(1)
+	return fakeTx{c.d}, nil
+}
+
+type fakeTx struct{ d *fakeDriver }
+
+func (tx fakeTx) Commit() error {
+	if tx.d.commits.Add(1) <= tx.d.commitFailures {
+		return tx.d.commitErr
+	}
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.d.rollbacks.Add(1)
+	return nil
+}
+
+func fakeDB(t *testing.T, d *fakeDriver) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(fakeConnector{d})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type fakeConnector struct{ d *fakeDriver }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c fakeConnector) Driver() driver.Driver                        { return c.d }
+
+func TestTxRetriesDeadlocks(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	ctx := context.Background()
+
+	d := &fakeDriver{commitErr: deadlock, commitFailures: 2}
+	tx := NewTxManager(fakeDB(t, d), DialectMySQL, Options{TxRetries: 3})
+	runs := 0
+	if err := tx.Run(ctx, func(context.Context) error { runs++; return nil }); err != nil {
+		t.Fatalf("Expected the third attempt to commit, got %v", err)
+	}
+	if runs != 3 || d.begins.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d runs and %d transactions", runs, d.begins.Load())
+	}
+
+	d = &fakeDriver{commitErr: deadlock, commitFailures: 10}
+	tx = NewTxManager(fakeDB(t, d), DialectMySQL, Options{TxRetries: 1})
+	if err := tx.Run(ctx, func(context.Context) error { return nil }); !errors.Is(err, deadlock) {
+		t.Errorf("Expected the deadlock once retries run out, got %v", err)
+	}
+	if d.begins.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", d.begins.Load())
+	}
+
+	d = &fakeDriver{commitErr: errors.New("connection reset"), commitFailures: 10}
+	tx = NewTxManager(fakeDB(t, d), DialectMySQL, Options{TxRetries: 3})
+	tx.Run(ctx, func(context.Context) error { return nil })
+	if d.begins.Load() != 1 {
+		t.Errorf("Expected other errors not to be retried, got %d attempts", d.begins.Load())
+	}
+}
+
+func TestTxRollsBackOnErrorAndPanic(t *testing.T) {
+	d := &fakeDriver{}
+	tx := NewTxManager(fakeDB(t, d), DialectMySQL, Options{})
+	ctx := context.Background()
+
+	failure := errors.New("step failed")
+	err := tx.Run(ctx, func(ctx context.Context) error {
+		return tx.Run(ctx, func(context.Context) error { return failure })
+	})
+	if !errors.Is(err, failure) || d.begins.Load() != 1 || d.rollbacks.Load() != 1 || d.commits.Load() != 0 {
+		t.Errorf("Expected one rolled back transaction, got %v with %d begins, %d rollbacks, %d commits",
+			err, d.begins.Load(), d.rollbacks.Load(), d.commits.Load())
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to propagate")
+			}
+		}()
+		tx.Run(ctx, func(context.Context) error { panic("boom") })
+	}()
+	if d.rollbacks.Load() != 2 {
+		t.Errorf("Expected a panic to roll back, got %d rollbacks", d.rollbacks.Load())
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1213}, true},
+		{&mysql.MySQLError{Number: 1205}, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{fmt.Errorf("commit: %w", &pgconn.PgError{Code: "40001"}), true},
+		{&pgconn.PgError{Code: "23505", Message: "duplicate key value: deadlock 40P01"}, false},
+		{errors.New("deadlock detected (SQLSTATE 40P01)"), false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,51 +1,142 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"taskmanager/models"
+	"time"
 )
 
+// UserRepository.DeleteUser moves users to the trash, which hides them from
+// every method but the ones named for deleted users. Their emails stay
+// taken until they are purged.
 type UserRepository interface {
-	CreateUser(user *models.User) error
-	GetUserByID(id int) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// UpdatePasswordHash and DeleteUser only write the user at version and
+	// fail with ErrVersionConflict otherwise.
+	UpdatePasswordHash(ctx context.Context, id, version int, hash string) error
+	DeleteUser(ctx context.Context, id, version int, at time.Time) error
+	GetDeletedUser(ctx context.Context, id int) (*models.User, error)
+	// ListDeletedUsers returns the users in the trash, most recently deleted
+	// first.
+	ListDeletedUsers(ctx context.Context) ([]models.User, error)
+	// RestoreUser takes the user out of the trash if it is still at version.
+	RestoreUser(ctx context.Context, id, version int) error
+	// PurgeDeletedUsers removes the users deleted before before for good,
+	// except those that tasks still reference; purge the tasks first.
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error)
 }
 
 type userRepository struct {
-	db *sql.DB
+	sqlStore
 }
 
-func NewUserRepository(db *sql.DB) UserRepository {
-	return &userRepository{db: db}
+func NewUserRepository(db *sql.DB, dialect Dialect, opts Options) UserRepository {
+	return &userRepository{sqlStore: newSQLStore(db, dialect, opts)}
 }
 
-func (r *userRepository) CreateUser(user *models.User) error {
-	query := "INSERT INTO users (name, email) VALUES (?, ?)"
-	result, err := r.db.Exec(query, user.Name, user.Email)
+const userColumns = "id, name, email, role, password_hash, version, deleted_at"
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var deletedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.PasswordHash, &user.Version, &deletedAt)
 	if err != nil {
-		return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
 	}
+	return &user, nil
+}
 
-	insertedID, err := result.LastInsertId()
+func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
+	query := "INSERT INTO users (name, email, role, password_hash) VALUES (?, ?, ?, ?)"
+	insertedID, err := r.insert(ctx, query, user.Name, user.Email, user.Role, user.PasswordHash)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
-	user.ID = int(insertedID)
+	user.ID = insertedID
+	user.Version = 1
 	return nil
 }
 
-func (r *userRepository) GetUserByID(id int) (*models.User, error) {
-	query := "SELECT id, name, email FROM users WHERE id = ?"
-	result := r.db.QueryRow(query, id)
+func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE id = ? AND " + live
+	return scanUser(r.queryRow(ctx, query, id))
+}
 
-	var user models.User
-	err := result.Scan(&user.ID, &user.Name, &user.Email)
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE email = ? AND " + live
+	return scanUser(r.queryRow(ctx, query, email))
+}
+
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id, version int, hash string) error {
+	query := "UPDATE users SET password_hash = ?, version = version + 1 WHERE id = ? AND version = ? AND " + live
+	result, err := r.exec(ctx, query, hash, id, version)
+	if err != nil {
+		return err
+	}
+	return r.checkVersioned(ctx, result, "users", live, id)
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id, version int, at time.Time) error {
+	query := "UPDATE users SET deleted_at = ?, version = version + 1 WHERE id = ? AND version = ? AND " + live
+	result, err := r.exec(ctx, query, at, id, version)
+	if err != nil {
+		return err
+	}
+	return r.checkVersioned(ctx, result, "users", live, id)
+}
+
+func (r *userRepository) GetDeletedUser(ctx context.Context, id int) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE id = ? AND " + trashed
+	return scanUser(r.queryRow(ctx, query, id))
+}
+
+func (r *userRepository) ListDeletedUsers(ctx context.Context) ([]models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE " + trashed + " ORDER BY deleted_at DESC, id"
+	rows, err := r.query(ctx, query)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
 		return nil, err
 	}
-	return &user, nil
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (r *userRepository) RestoreUser(ctx context.Context, id, version int) error {
+	query := "UPDATE users SET deleted_at = NULL, version = version + 1 WHERE id = ? AND version = ? AND " + trashed
+	result, err := r.exec(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+	return r.checkVersioned(ctx, result, "users", trashed, id)
+}
+
+func (r *userRepository) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM users WHERE deleted_at < ?
+		AND NOT EXISTS (SELECT 1 FROM tasks WHERE tasks.user_id = users.id)`
+	result, err := r.exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"taskmanager/openapi"
+	"testing"
+)
+
+// TestRoutesAreDocumented checks that the routes registered in main.go and
+// the operations in the OpenAPI document are the same set.
+func TestRoutesAreDocumented(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		var name string
+		switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			name = fn.Name
+		case *ast.SelectorExpr:
+			if x, ok := fn.X.(*ast.Ident); ok && x.Name == "mux" {
+				name = fn.Sel.Name
+			}
+		}
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && slices.Contains([]string{"route", "public", "Handle", "HandleFunc"}, name) {
+			pattern, _ := strconv.Unquote(lit.Value)
+			routes = append(routes, pattern)
+		}
+		return true
+	})
+	if len(routes) == 0 {
+		t.Fatal("Found no routes in main.go")
+	}
+
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, route := range routes {
+		if _, ok := doc.Operation(route); !ok {
+			t.Errorf("%s is registered but not documented", route)
+		}
+	}
+	for _, pattern := range doc.Patterns() {
+		if !slices.Contains(routes, pattern) {
+			t.Errorf("%s is documented but not registered", pattern)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"taskmanager/auth"
+	"taskmanager/logging"
+	"taskmanager/models"
+	"taskmanager/repository"
+	"time"
+)
+
+type AuditService interface {
+	ListAuditEvents(ctx context.Context, q *models.AuditQuery) (*models.AuditPage, error)
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) AuditService {
+	return &auditService{auditRepo: auditRepo}
+}
+
+// ListAuditEvents is for admins only, since the log covers every user.
+func (s *auditService) ListAuditEvents(ctx context.Context, q *models.AuditQuery) (*models.AuditPage, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := normalizeAuditQuery(q); err != nil {
+		return nil, err
+	}
+
+	events, next, err := s.auditRepo.ListAuditEvents(ctx, *q)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	page := &models.AuditPage{Events: events}
+	if next != 0 {
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(next)))
+	}
+	return page, nil
+}
+
+func normalizeAuditQuery(q *models.AuditQuery) error {
+	var fields []FieldError
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultPageSize
+	case q.Limit < 0 || q.Limit > MaxPageSize:
+		fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", MaxPageSize)})
+	}
+	if q.Action != "" && !q.Action.Valid() {
+		fields = append(fields, FieldError{Field: "action", Message: fmt.Sprintf("unknown action %q", q.Action)})
+	}
+	if q.EntityType != "" && q.EntityType != models.AuditEntityTask && q.EntityType != models.AuditEntityUser {
+		fields = append(fields, FieldError{Field: "entity_type", Message: "must be task or user"})
+	}
+	if q.Since != nil && q.Until != nil && !q.Since.Before(*q.Until) {
+		fields = append(fields, FieldError{Field: "since", Message: "must be before until"})
+	}
+	if q.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if q.Before, _ = strconv.Atoi(string(data)); err != nil || q.Before <= 0 {
+			fields = append(fields, FieldError{Field: "cursor", Message: "is not a valid cursor for this listing"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+	return nil
+}
+
+// recordAudit appends an event for a change to the entity of entityType
+// and id. Call it in the transaction that makes the change.
+func recordAudit(ctx context.Context, auditRepo repository.AuditRepository, action models.AuditAction, entityType string, id int, changes map[string]models.AuditChange) error {
+	event := &models.AuditEvent{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   id,
+		Changes:    changes,
+		RequestID:  logging.RequestID(ctx),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		event.ActorID = p.UserID
+	}
+	return internalError(auditRepo.RecordAuditEvent(ctx, event))
+}
+
+// diff compares the JSON encodings of before and after, either of which
+// may be nil, field by field.
+func diff(before, after any) map[string]models.AuditChange {
+	var old, cur map[string]json.RawMessage
+	if data, err := json.Marshal(before); err == nil {
+		json.Unmarshal(data, &old)
+	}
+	if data, err := json.Marshal(after); err == nil {
+		json.Unmarshal(data, &cur)
+	}
+
+	changes := map[string]models.AuditChange{}
+	for field, value := range cur {
+		if !bytes.Equal(old[field], value) {
+			changes[field] = models.AuditChange{Before: old[field], After: value}
+		}
+	}
+	for field, value := range old {
+		if _, ok := cur[field]; !ok {
+			changes[field] = models.AuditChange{Before: value}
+		}
+	}
+	return changes
+}
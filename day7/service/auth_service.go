@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"taskmanager/repository"
+	"time"
+)
+
+type AuthService interface {
+	IssueAPIKey(ctx context.Context, userID int) (string, error)
+	Login(ctx context.Context, creds models.Credentials) (*models.AccessToken, error)
+	Logout(ctx context.Context) error
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+}
+
+type authService struct {
+	userRepo    repository.UserRepository
+	apiKeyRepo  repository.APIKeyRepository
+	sessionRepo repository.SessionRepository
+	tx          repository.TxManager
+	signer      *auth.Signer
+	logger      *slog.Logger
+	now         func() time.Time
+}
+
+func NewAuthService(userRepo repository.UserRepository, apiKeyRepo repository.APIKeyRepository, sessionRepo repository.SessionRepository, tx repository.TxManager, signer *auth.Signer, logger *slog.Logger) AuthService {
+	return &authService{
+		userRepo:    userRepo,
+		apiKeyRepo:  apiKeyRepo,
+		sessionRepo: sessionRepo,
+		tx:          tx,
+		signer:      signer,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+// IssueAPIKey creates a new key for userID. The plaintext key is returned
+// once and cannot be recovered afterwards.
+func (s *authService) IssueAPIKey(ctx context.Context, userID int) (string, error) {
+	if err := authorize(ctx, userID); err != nil {
+		return "", err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", internalError(err)
+	}
+	if user == nil {
+		return "", ErrUserNotFound
+	}
+
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return "", internalError(err)
+	}
+	record := models.APIKey{UserID: userID, Hash: hash, CreatedAt: s.now().UTC()}
+	if err := s.apiKeyRepo.CreateAPIKey(ctx, &record); err != nil {
+		return "", internalError(err)
+	}
+
+	s.logger.InfoContext(ctx, "api key issued", slog.Int("key_user_id", userID), slog.Int("api_key_id", record.ID))
+	return key, nil
+}
+
+// Login starts a session for an email and password or for an API key and
+// returns an access token bound to it.
+func (s *authService) Login(ctx context.Context, creds models.Credentials) (*models.AccessToken, error) {
+	var user *models.User
+	var err error
+	method := "password"
+	if creds.APIKey != "" {
+		method = "api_key"
+		user, err = s.userForAPIKey(ctx, creds.APIKey)
+	} else {
+		user, err = s.userForPassword(ctx, creds.Email, creds.Password)
+	}
+	if errors.Is(err, ErrInvalidCredential) {
+		s.logger.InfoContext(ctx, "login failed", slog.String("method", method))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "login succeeded", slog.String("method", method), slog.Int("login_user_id", user.ID))
+	return token, nil
+}
+
+func (s *authService) userForAPIKey(ctx context.Context, apiKey string) (*models.User, error) {
+	key, err := s.apiKeyRepo.GetAPIKeyByHash(ctx, auth.HashAPIKey(apiKey))
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if key == nil {
+		return nil, ErrInvalidCredential
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if user == nil {
+		return nil, ErrInvalidCredential
+	}
+	return user, nil
+}
+
+// userForPassword gives the same answer, after the same amount of work, for
+// an unknown email as for a wrong password.
+func (s *authService) userForPassword(ctx context.Context, email, password string) (*models.User, error) {
+	var fields []FieldError
+	if email == "" {
+		fields = append(fields, FieldError{Field: "email", Message: "is required"})
+	}
+	if password == "" {
+		fields = append(fields, FieldError{Field: "password", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return nil, validationError(fields...)
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, normalizeEmail(email))
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if user == nil || user.PasswordHash == "" {
+		auth.CheckDummyPassword(password)
+		return nil, ErrInvalidCredential
+	}
+
+	ok, err := auth.CheckPassword(user.PasswordHash, password)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if !ok {
+		return nil, ErrInvalidCredential
+	}
+	return user, nil
+}
+
+func (s *authService) startSession(ctx context.Context, user *models.User) (*models.AccessToken, error) {
+	id, err := auth.NewSessionID()
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	token, expires, err := s.signer.Sign(auth.Principal{UserID: user.ID, Role: user.Role, SessionID: id})
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	// Sessions are also revoked inside transactions, so they are written
+	// through one too.
+	session := models.Session{ID: id, UserID: user.ID, CreatedAt: s.now().UTC(), ExpiresAt: expires.UTC()}
+	err = inTx(ctx, s.tx, func(ctx context.Context) error {
+		return s.sessionRepo.CreateSession(ctx, &session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.AccessToken{AccessToken: token, TokenType: "Bearer", ExpiresAt: expires}, nil
+}
+
+// Logout ends the session the caller's token belongs to.
+func (s *authService) Logout(ctx context.Context) error {
+	p, ok := auth.FromContext(ctx)
+	if !ok || p.SessionID == "" {
+		return ErrUnauthenticated
+	}
+	err := inTx(ctx, s.tx, func(ctx context.Context) error {
+		return s.sessionRepo.RevokeSession(ctx, p.SessionID, s.now().UTC())
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "logged out")
+	return nil
+}
+
+// Authenticate accepts a token only while the session it was issued for is
+// still active, so logging out or changing the password ends it early.
+func (s *authService) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	p, err := s.signer.Verify(token)
+	if err != nil {
+		return auth.Principal{}, ErrUnauthenticated.withMessage(err.Error())
+	}
+	if p.SessionID == "" {
+		return auth.Principal{}, ErrUnauthenticated.withMessage("token is not bound to a session")
+	}
+
+	session, err := s.sessionRepo.GetSession(ctx, p.SessionID)
+	if err != nil {
+		return auth.Principal{}, internalError(err)
+	}
+	if session == nil || session.UserID != p.UserID || !session.Active(s.now()) {
+		return auth.Principal{}, ErrUnauthenticated.withMessage("session has ended")
+	}
+	return p, nil
+}
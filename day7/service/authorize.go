@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"taskmanager/auth"
+)
+
+// authorize allows admins everywhere and everyone else only on resources
+// owned by their own user id.
+func authorize(ctx context.Context, ownerID int) error {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if p.IsAdmin() || p.UserID == ownerID {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// authorizeAdmin allows admins only.
+func authorizeAdmin(ctx context.Context) error {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if !p.IsAdmin() {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import "fmt"
+
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindUnprocessable
+	KindUnauthenticated
+	KindForbidden
+	KindFailedDependency
+	KindPreconditionFailed
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is the error type every service method returns. Code is a stable,
+// machine-readable identifier; Message is safe to show to API clients.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors by code, so errors.Is(err, ErrTaskNotFound) holds for
+// any task_not_found error regardless of its message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrTaskNotFound      = &Error{Kind: KindNotFound, Code: "task_not_found", Message: "task not found"}
+	ErrUserNotFound      = &Error{Kind: KindNotFound, Code: "user_not_found", Message: "user not found"}
+	ErrInvalidTransition = &Error{Kind: KindConflict, Code: "invalid_transition", Message: "invalid status transition"}
+	ErrUnknownUser       = &Error{Kind: KindUnprocessable, Code: "unknown_user", Message: "user does not exist"}
+	ErrUserHasTasks      = &Error{Kind: KindConflict, Code: "user_has_tasks", Message: "user still owns tasks"}
+	ErrEmailTaken        = &Error{Kind: KindConflict, Code: "email_taken", Message: "email address is already registered"}
+	ErrUnauthenticated   = &Error{Kind: KindUnauthenticated, Code: "unauthenticated", Message: "authentication required"}
+	ErrInvalidCredential = &Error{Kind: KindUnauthenticated, Code: "invalid_credentials", Message: "invalid credentials"}
+	ErrForbidden         = &Error{Kind: KindForbidden, Code: "forbidden", Message: "not allowed to access this resource"}
+	ErrValidation        = &Error{Kind: KindValidation, Code: "validation_failed", Message: "request failed validation"}
+	ErrBatchAborted      = &Error{Kind: KindFailedDependency, Code: "batch_aborted", Message: "not applied because another item of the batch failed"}
+	ErrVersionMismatch   = &Error{Kind: KindPreconditionFailed, Code: "version_mismatch", Message: "resource was changed since it was read"}
+	ErrInternal          = &Error{Kind: KindInternal, Code: "internal", Message: "internal server error"}
+)
+
+func validationError(fields ...FieldError) error {
+	return &Error{Kind: KindValidation, Code: ErrValidation.Code, Message: ErrValidation.Message, Fields: fields}
+}
+
+func (e *Error) withMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// internalError hides a storage failure behind a generic message while
+// keeping it reachable through errors.Is/As for logging and context checks.
+func internalError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: KindInternal, Code: ErrInternal.Code, Message: ErrInternal.Message, Err: err}
+}
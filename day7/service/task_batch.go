@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"taskmanager/repository"
+)
+
+// MaxBatchSize caps the number of items in one batch.
+const MaxBatchSize = 100
+
+// BatchResult reports a batch item by item, in request order. Committed is
+// false when an atomic batch was rolled back and nothing was written.
+type BatchResult struct {
+	Committed bool
+	Items     []BatchItemResult
+}
+
+// BatchItemResult holds the task an item created or updated, or the error
+// that stopped it. Deletes leave Task nil.
+type BatchItemResult struct {
+	Action models.BatchAction
+	ID     int
+	Task   *models.Task
+	Err    error
+}
+
+// BatchTasks checks every item as the single-task methods would, then
+// writes the ones that passed in one transaction. In atomic mode one failing
+// item aborts the batch and the others report ErrBatchAborted.
+func (s *taskService) BatchTasks(ctx context.Context, batch *models.TaskBatch) (*BatchResult, error) {
+	if _, ok := auth.FromContext(ctx); !ok {
+		return nil, ErrUnauthenticated
+	}
+	if batch.Mode == "" {
+		batch.Mode = models.BatchAtomic
+	}
+	if err := validateBatch(batch); err != nil {
+		return nil, err
+	}
+	atomic := batch.Mode == models.BatchAtomic
+
+	result := &BatchResult{Items: make([]BatchItemResult, len(batch.Items))}
+	var ops []repository.TaskOp
+	var opItems []int
+	var befores []*models.Task
+	seen := map[int]bool{}
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		before, task, err := s.prepareBatchItem(ctx, item, seen)
+		result.Items[i] = BatchItemResult{Action: item.Action, ID: item.ID, Err: err}
+		if err != nil {
+			continue
+		}
+		if item.Action != models.BatchDelete {
+			result.Items[i].Task = task
+		}
+		ops = append(ops, repository.TaskOp{Action: item.Action, Task: task})
+		opItems = append(opItems, i)
+		befores = append(befores, before)
+	}
+
+	if len(ops) > 0 && (!atomic || len(ops) == len(batch.Items)) {
+		var errs []error
+		err := inTx(ctx, s.tx, func(ctx context.Context) error {
+			var err error
+			if errs, err = s.taskRepo.ApplyBatch(ctx, ops, atomic); err != nil {
+				return internalError(err)
+			}
+			return s.auditBatch(ctx, ops, befores, errs, atomic)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for j, err := range errs {
+			if err != nil {
+				item := &result.Items[opItems[j]]
+				item.Err = s.storageError(err, ops[j].Task.UserId)
+				item.Task = nil
+			}
+		}
+	}
+
+	failed := 0
+	for _, item := range result.Items {
+		if item.Err != nil {
+			failed++
+		}
+	}
+	if atomic && failed > 0 {
+		for i := range result.Items {
+			if item := &result.Items[i]; item.Err == nil {
+				item.Err = ErrBatchAborted
+				item.Task = nil
+			}
+		}
+		s.logger.InfoContext(ctx, "task batch aborted", slog.Int("items", len(batch.Items)), slog.Int("failed", failed))
+		return result, nil
+	}
+
+	result.Committed = true
+	s.logger.InfoContext(ctx, "task batch applied", slog.String("mode", string(batch.Mode)),
+		slog.Int("items", len(batch.Items)), slog.Int("failed", failed))
+	return result, nil
+}
+
+func validateBatch(batch *models.TaskBatch) error {
+	var fields []FieldError
+	if !batch.Mode.Valid() {
+		fields = append(fields, FieldError{Field: "mode", Message: fmt.Sprintf("unknown mode %q", batch.Mode)})
+	}
+	switch n := len(batch.Items); {
+	case n == 0:
+		fields = append(fields, FieldError{Field: "items", Message: "must not be empty"})
+	case n > MaxBatchSize:
+		fields = append(fields, FieldError{Field: "items", Message: fmt.Sprintf("must not contain more than %d items", MaxBatchSize)})
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+	return nil
+}
+
+// auditBatch records the ops ApplyBatch wrote, which is none of them when
+// an atomic batch failed.
+func (s *taskService) auditBatch(ctx context.Context, ops []repository.TaskOp, befores []*models.Task, errs []error, atomic bool) error {
+	if atomic && slices.ContainsFunc(errs, func(err error) bool { return err != nil }) {
+		return nil
+	}
+	actions := map[models.BatchAction]models.AuditAction{
+		models.BatchCreate: models.AuditCreate,
+		models.BatchUpdate: models.AuditUpdate,
+		models.BatchDelete: models.AuditDelete,
+	}
+	for j, op := range ops {
+		if errs[j] != nil {
+			continue
+		}
+		after := op.Task
+		if op.Action == models.BatchDelete {
+			deleted := *op.Task
+			deleted.Version++
+			after = &deleted
+		}
+		if err := recordAudit(ctx, s.auditRepo, actions[op.Action], models.AuditEntityTask, op.Task.ID, diff(befores[j], after)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareBatchItem checks an item and returns the task it writes and the
+// task as it was before, nil for creates. seen holds the ids of earlier
+// items, since a task may only appear once per batch.
+func (s *taskService) prepareBatchItem(ctx context.Context, item *models.TaskBatchItem, seen map[int]bool) (before, task *models.Task, err error) {
+	var fields []FieldError
+	unexpected := func(field string, present bool) {
+		if present {
+			fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf("is not allowed for %s", item.Action)})
+		}
+	}
+	switch item.Action {
+	case models.BatchCreate:
+		if item.Task == nil {
+			fields = append(fields, FieldError{Field: "task", Message: "is required"})
+		}
+		unexpected("id", item.ID != 0)
+		unexpected("version", item.Version != 0)
+		unexpected("patch", item.Patch != nil)
+		unexpected("status", item.Status != "")
+	case models.BatchUpdate, models.BatchDelete:
+		if item.ID <= 0 {
+			fields = append(fields, FieldError{Field: "id", Message: "must be a positive id"})
+		} else if seen[item.ID] {
+			fields = append(fields, FieldError{Field: "id", Message: "appears in an earlier item of the batch"})
+		}
+		// Without a version a batch could overwrite a change it never saw,
+		// which the single-task endpoints prevent by requiring If-Match.
+		if item.Version <= 0 {
+			fields = append(fields, FieldError{Field: "version", Message: "is required"})
+		}
+		unexpected("task", item.Task != nil)
+		if item.Action == models.BatchUpdate && item.Patch == nil && item.Status == "" {
+			fields = append(fields, FieldError{Field: "patch", Message: "or status is required"})
+		}
+		if item.Action == models.BatchDelete {
+			unexpected("patch", item.Patch != nil)
+			unexpected("status", item.Status != "")
+		}
+		seen[item.ID] = true
+	default:
+		fields = append(fields, FieldError{Field: "action", Message: fmt.Sprintf("unknown action %q", item.Action)})
+	}
+	if len(fields) > 0 {
+		return nil, nil, validationError(fields...)
+	}
+
+	if item.Action == models.BatchCreate {
+		task := *item.Task
+		task.ID = 0
+		if err := s.prepareCreate(ctx, &task); err != nil {
+			return nil, nil, err
+		}
+		return nil, &task, nil
+	}
+
+	task, err = s.getVersion(ctx, item.ID, item.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	original := *task
+	now := s.now()
+	if item.Action == models.BatchDelete {
+		task.DeletedAt = &now
+		return &original, task, nil
+	}
+
+	if item.Patch != nil {
+		if err := checkEditable(task); err != nil {
+			return nil, nil, err
+		}
+		applyPatch(task, item.Patch)
+		if err := validateTask(task); err != nil {
+			return nil, nil, err
+		}
+	}
+	if item.Status != "" {
+		if err := applyTransition(task, item.Status, now); err != nil {
+			return nil, nil, err
+		}
+	}
+	task.UpdatedAt = now
+	return &original, task, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"taskmanager/models"
+)
+
+// pageCursor is what an opaque next_cursor decodes to. The sort order is
+// kept so a cursor cannot be replayed against a differently sorted listing.
+type pageCursor struct {
+	Sort string `json:"s"`
+	models.TaskCursor
+}
+
+func encodeCursor(sort models.TaskSort, cursor *models.TaskCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	data, _ := json.Marshal(pageCursor{Sort: sort.String(), TaskCursor: *cursor})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(sort models.TaskSort, token string) (*models.TaskCursor, error) {
+	invalid := validationError(FieldError{Field: "cursor", Message: "is not a valid cursor for this listing"})
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort.String() {
+		return nil, invalid
+	}
+	return &cursor.TaskCursor, nil
+}
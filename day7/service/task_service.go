@@ -1,37 +1,498 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"taskmanager/auth"
 	"taskmanager/models"
 	"taskmanager/repository"
+	"time"
+	"unicode/utf8"
 )
 
 type TaskService interface {
-	CreateTask(task *models.Task) error
-	GetTasksForUser(userID int) ([]models.Task, error)
+	CreateTask(ctx context.Context, task *models.Task) error
+	GetTask(ctx context.Context, id int) (*models.Task, error)
+	ListTasks(ctx context.Context, q *models.TaskQuery) (*models.TaskPage, error)
+	UpdateTask(ctx context.Context, task *models.Task) error
+	PatchTask(ctx context.Context, id, version int, patch *models.TaskPatch) (*models.Task, error)
+	TransitionTask(ctx context.Context, id, version int, to models.TaskStatus) (*models.Task, error)
+	DeleteTask(ctx context.Context, id, version int) error
+	BatchTasks(ctx context.Context, batch *models.TaskBatch) (*BatchResult, error)
+	ListTrash(ctx context.Context, userID int) (*models.Trash, error)
+	RestoreTask(ctx context.Context, id, version int) (*models.Task, error)
 }
 
 type taskService struct {
-	taskRepo repository.TaskRepository
+	taskRepo  repository.TaskRepository
+	userRepo  repository.UserRepository
+	auditRepo repository.AuditRepository
+	tx        repository.TxManager
+	logger    *slog.Logger
+	now       func() time.Time
 }
 
-func NewTaskService(taskRepo repository.TaskRepository) TaskService {
-	return &taskService{taskRepo: taskRepo}
+func NewTaskService(taskRepo repository.TaskRepository, userRepo repository.UserRepository, auditRepo repository.AuditRepository, tx repository.TxManager, logger *slog.Logger) TaskService {
+	return &taskService{
+		taskRepo:  taskRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		tx:        tx,
+		logger:    logger,
+		now:       func() time.Time { return time.Now().UTC() },
+	}
 }
 
-func (s *taskService) CreateTask(task *models.Task) error {
-	if task.Title == "" {
-		return errors.New("task title is required")
+// CreateTask assigns the task to the caller unless an admin names another
+// owner in user_id.
+func (s *taskService) CreateTask(ctx context.Context, task *models.Task) error {
+	if err := s.prepareCreate(ctx, task); err != nil {
+		return err
+	}
+	err := s.audited(ctx, models.AuditCreate, nil, task, func(ctx context.Context) error {
+		return s.taskRepo.CreateTask(ctx, task)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "task created", slog.Int("task_id", task.ID), slog.Int("owner_id", task.UserId))
+	return nil
+}
+
+// prepareCreate fills in the defaults and timestamps of a new task and
+// checks that the caller may create it.
+func (s *taskService) prepareCreate(ctx context.Context, task *models.Task) error {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
 	}
 	if task.UserId == 0 {
-		return errors.New("user_id is required")
+		task.UserId = p.UserID
+	}
+	if err := authorize(ctx, task.UserId); err != nil {
+		return err
+	}
+
+	if task.Status == "" {
+		task.Status = models.StatusOpen
+	}
+	if task.Priority == "" {
+		task.Priority = models.PriorityMedium
+	}
+	if err := validateTask(task); err != nil {
+		return err
+	}
+	if err := checkInitialStatus(task.Status); err != nil {
+		return err
+	}
+	if err := s.ensureOwner(ctx, task.UserId); err != nil {
+		return err
+	}
+
+	task.CreatedAt = s.now()
+	task.UpdatedAt = task.CreatedAt
+	task.CompletedAt, task.DeletedAt = nil, nil
+	return nil
+}
+
+func (s *taskService) GetTask(ctx context.Context, id int) (*models.Task, error) {
+	task, err := s.taskRepo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+	if err := authorize(ctx, task.UserId); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+func (s *taskService) ListTasks(ctx context.Context, q *models.TaskQuery) (*models.TaskPage, error) {
+	if err := normalizeQuery(q); err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, q.UserID); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, q.UserID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	tasks, next, err := s.taskRepo.ListTasks(ctx, *q)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+	return &models.TaskPage{Tasks: tasks, NextCursor: encodeCursor(q.Sort, next)}, nil
+}
+
+func normalizeQuery(q *models.TaskQuery) error {
+	var fields []FieldError
+	if q.UserID <= 0 {
+		fields = append(fields, FieldError{Field: "user_id", Message: "must be a positive id"})
 	}
-	return s.taskRepo.CreateTask(task)
+
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultPageSize
+	case q.Limit < 0 || q.Limit > MaxPageSize:
+		fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", MaxPageSize)})
+	}
+
+	if q.Sort.Field == "" {
+		q.Sort.Field = models.SortByID
+	}
+	if !q.Sort.Valid() {
+		fields = append(fields, FieldError{Field: "sort", Message: fmt.Sprintf("cannot sort by %q", q.Sort.Field)})
+	}
+
+	for _, status := range q.Statuses {
+		if !status.Valid() {
+			fields = append(fields, FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", status)})
+		}
+	}
+	for _, priority := range q.Priorities {
+		if !priority.Valid() {
+			fields = append(fields, FieldError{Field: "priority", Message: fmt.Sprintf("unknown priority %q", priority)})
+		}
+	}
+	if q.DueBefore != nil && q.DueAfter != nil && !q.DueAfter.Before(*q.DueBefore) {
+		fields = append(fields, FieldError{Field: "due_after", Message: "must be before due_before"})
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Sort, q.Cursor)
+		if err != nil {
+			return err
+		}
+		q.After = after
+	}
+	return nil
+}
+
+// UpdateTask replaces the editable fields of a task that is not archived;
+// status only changes through TransitionTask. It fails with ErrVersionMismatch if the task has
+// changed since the caller read task.Version.
+func (s *taskService) UpdateTask(ctx context.Context, task *models.Task) error {
+	existing, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion("task", task.ID, task.Version, existing.Version); err != nil {
+		return err
+	}
+	if err := checkEditable(existing); err != nil {
+		return err
+	}
+	task.Version = existing.Version
+
+	if task.Priority == "" {
+		task.Priority = models.PriorityMedium
+	}
+	task.Status = existing.Status
+	task.CreatedAt = existing.CreatedAt
+	task.CompletedAt = existing.CompletedAt
+	task.DeletedAt = nil
+	if err := validateTask(task); err != nil {
+		return err
+	}
+	if task.UserId != existing.UserId {
+		if err := authorize(ctx, task.UserId); err != nil {
+			return err
+		}
+		if err := s.ensureOwner(ctx, task.UserId); err != nil {
+			return err
+		}
+	}
+
+	task.UpdatedAt = s.now()
+	err = s.audited(ctx, models.AuditUpdate, existing, task, func(ctx context.Context) error {
+		return s.taskRepo.UpdateTask(ctx, task)
+	})
+	if err != nil {
+		return err
+	}
+
+	if task.UserId != existing.UserId {
+		s.logger.InfoContext(ctx, "task reassigned", slog.Int("task_id", task.ID),
+			slog.Int("from_owner_id", existing.UserId), slog.Int("owner_id", task.UserId))
+	}
+	return nil
 }
 
-func (s *taskService) GetTasksForUser(userID int) ([]models.Task, error) {
+func (s *taskService) PatchTask(ctx context.Context, id, version int, patch *models.TaskPatch) (*models.Task, error) {
+	task, err := s.getVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkEditable(task); err != nil {
+		return nil, err
+	}
+	before := *task
+	applyPatch(task, patch)
+	if err := validateTask(task); err != nil {
+		return nil, err
+	}
+	task.UpdatedAt = s.now()
+	err = s.audited(ctx, models.AuditUpdate, &before, task, func(ctx context.Context) error {
+		return s.taskRepo.UpdateTask(ctx, task)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *taskService) TransitionTask(ctx context.Context, id, version int, to models.TaskStatus) (*models.Task, error) {
+	task, err := s.getVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	before := *task
+	if err := applyTransition(task, to, s.now()); err != nil {
+		return nil, err
+	}
+
+	err = s.audited(ctx, models.AuditUpdate, &before, task, func(ctx context.Context) error {
+		return s.taskRepo.UpdateTask(ctx, task)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "task transitioned", slog.Int("task_id", task.ID),
+		slog.String("from", string(before.Status)), slog.String("to", string(to)))
+	return task, nil
+}
+
+func applyPatch(task *models.Task, patch *models.TaskPatch) {
+	if patch.Title != nil {
+		task.Title = *patch.Title
+	}
+	if patch.Description != nil {
+		task.Description = *patch.Description
+	}
+	if patch.Priority != nil {
+		task.Priority = *patch.Priority
+	}
+	if patch.DueDate != nil {
+		task.DueDate = patch.DueDate
+	}
+}
+
+func applyTransition(task *models.Task, to models.TaskStatus, now time.Time) error {
+	if err := checkTransition(task.Status, to); err != nil {
+		return err
+	}
+
+	switch {
+	case to == models.StatusDone:
+		task.CompletedAt = &now
+	case to != models.StatusArchived:
+		task.CompletedAt = nil
+	}
+	task.Status = to
+	task.UpdatedAt = now
+	return nil
+}
+
+// DeleteTask moves the task to the trash, from which RestoreTask brings it
+// back until it is purged.
+func (s *taskService) DeleteTask(ctx context.Context, id, version int) error {
+	task, err := s.getVersion(ctx, id, version)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	deleted := *task
+	deleted.DeletedAt = &now
+	deleted.Version++
+	err = s.audited(ctx, models.AuditDelete, task, &deleted, func(ctx context.Context) error {
+		return s.taskRepo.DeleteTask(ctx, id, task.Version, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "task deleted", slog.Int("task_id", id))
+	return nil
+}
+
+// ListTrash returns a user's deleted tasks, the caller's when userID is 0.
+// Admins also get every deleted user.
+func (s *taskService) ListTrash(ctx context.Context, userID int) (*models.Trash, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
 	if userID == 0 {
-		return nil, errors.New("user_id must be valid")
+		userID = p.UserID
+	}
+	if err := authorize(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.ListDeletedTasks(ctx, userID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	trash := &models.Trash{Tasks: tasks}
+	if trash.Tasks == nil {
+		trash.Tasks = []models.Task{}
+	}
+	if p.IsAdmin() {
+		if trash.Users, err = s.userRepo.ListDeletedUsers(ctx); err != nil {
+			return nil, internalError(err)
+		}
+	}
+	return trash, nil
+}
+
+// RestoreTask takes a task out of the trash. Tasks of a deleted user stay
+// there until the user is restored.
+func (s *taskService) RestoreTask(ctx context.Context, id, version int) (*models.Task, error) {
+	task, err := s.taskRepo.GetDeletedTask(ctx, id)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if task == nil {
+		return nil, ErrTaskNotFound.withMessage(fmt.Sprintf("task %d is not in the trash", id))
+	}
+	if err := authorize(ctx, task.UserId); err != nil {
+		return nil, err
+	}
+	if err := checkVersion("task", id, version, task.Version); err != nil {
+		return nil, err
+	}
+	if err := s.ensureOwner(ctx, task.UserId); err != nil {
+		return nil, err
+	}
+
+	restored := *task
+	restored.DeletedAt = nil
+	restored.Version++
+	err = s.audited(ctx, models.AuditRestore, task, &restored, func(ctx context.Context) error {
+		return s.taskRepo.RestoreTask(ctx, id, task.Version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "task restored", slog.Int("task_id", id))
+	return &restored, nil
+}
+
+// audited runs write and records the change from before to task as one
+// unit of work. task.Version is reset on each attempt, since a retried
+// transaction would otherwise bump it twice.
+func (s *taskService) audited(ctx context.Context, action models.AuditAction, before, task *models.Task, write func(ctx context.Context) error) error {
+	version := task.Version
+	return inTx(ctx, s.tx, func(ctx context.Context) error {
+		task.Version = version
+		if err := write(ctx); err != nil {
+			return s.storageError(err, task.UserId)
+		}
+		return recordAudit(ctx, s.auditRepo, action, models.AuditEntityTask, task.ID, diff(before, task))
+	})
+}
+
+// getVersion is GetTask for a caller about to write the task it read at
+// version.
+func (s *taskService) getVersion(ctx context.Context, id, version int) (*models.Task, error) {
+	task, err := s.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion("task", id, version, task.Version); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ensureOwner rejects tasks assigned to a user that does not exist, before
+// the database has a chance to fail on its foreign key.
+func (s *taskService) ensureOwner(ctx context.Context, userID int) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return internalError(err)
+	}
+	if user == nil {
+		return unknownUser("user_id", userID)
+	}
+	return nil
+}
+
+// storageError maps the races between reading a task and writing it.
+func (s *taskService) storageError(err error, userID int) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrTaskNotFound
+	case errors.Is(err, repository.ErrVersionConflict):
+		return ErrVersionMismatch
+	case errors.Is(err, repository.ErrForeignKey):
+		return unknownUser("user_id", userID)
+	}
+	return internalError(err)
+}
+
+func unknownUser(field string, userID int) error {
+	err := ErrUnknownUser.withMessage(fmt.Sprintf("user %d does not exist", userID))
+	err.Fields = []FieldError{{Field: field, Message: "must reference an existing user"}}
+	return err
+}
+
+func validateTask(task *models.Task) error {
+	var fields []FieldError
+	if task.Title == "" {
+		fields = append(fields, FieldError{Field: "title", Message: "is required"})
+	} else if f, ok := validateLength("title", task.Title); !ok {
+		fields = append(fields, f)
+	}
+	if task.UserId <= 0 {
+		fields = append(fields, FieldError{Field: "user_id", Message: "is required"})
+	}
+	if !task.Status.Valid() {
+		fields = append(fields, FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", task.Status)})
+	}
+	if !task.Priority.Valid() {
+		fields = append(fields, FieldError{Field: "priority", Message: fmt.Sprintf("unknown priority %q", task.Priority)})
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields...)
+	}
+	return nil
+}
+
+// maxTextLength is the size of the VARCHAR(255) columns that hold task
+// titles and user names and emails.
+const maxTextLength = 255
+
+func validateLength(field, value string) (FieldError, bool) {
+	if utf8.RuneCountInString(value) > maxTextLength {
+		return FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxTextLength)}, false
 	}
-	return s.taskRepo.GetTasksByUserID(userID)
+	return FieldError{}, true
 }
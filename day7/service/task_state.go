@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"taskmanager/models"
+)
+
+// Archived tasks are terminal; every other status may be archived.
+var taskTransitions = map[models.TaskStatus][]models.TaskStatus{
+	models.StatusOpen:       {models.StatusInProgress, models.StatusBlocked, models.StatusDone, models.StatusArchived},
+	models.StatusInProgress: {models.StatusOpen, models.StatusBlocked, models.StatusDone, models.StatusArchived},
+	models.StatusBlocked:    {models.StatusOpen, models.StatusInProgress, models.StatusArchived},
+	models.StatusDone:       {models.StatusOpen, models.StatusArchived},
+	models.StatusArchived:   {},
+}
+
+// New tasks must start in one of these statuses and reach the others
+// through taskTransitions.
+var initialStatuses = []models.TaskStatus{models.StatusOpen}
+
+func CanTransition(from, to models.TaskStatus) bool {
+	for _, next := range taskTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTransition(from, to models.TaskStatus) error {
+	if !to.Valid() {
+		return validationError(FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", to)})
+	}
+	if !CanTransition(from, to) {
+		return ErrInvalidTransition.withMessage(fmt.Sprintf("cannot move task from %s to %s", from, to))
+	}
+	return nil
+}
+
+func checkInitialStatus(status models.TaskStatus) error {
+	if !slices.Contains(initialStatuses, status) {
+		return validationError(FieldError{Field: "status", Message: fmt.Sprintf("new tasks cannot start as %s", status)})
+	}
+	return nil
+}
+
+// checkEditable rejects edits to archived tasks, since archived is terminal.
+func checkEditable(task *models.Task) error {
+	if task.Status == models.StatusArchived {
+		return ErrInvalidTransition.withMessage("archived tasks cannot be edited")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"taskmanager/models"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.TaskStatus
+		want     bool
+	}{
+		{models.StatusOpen, models.StatusInProgress, true},
+		{models.StatusInProgress, models.StatusDone, true},
+		{models.StatusBlocked, models.StatusDone, false},
+		{models.StatusDone, models.StatusOpen, true},
+		{models.StatusArchived, models.StatusOpen, false},
+		{models.StatusOpen, models.StatusOpen, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCheckInitialStatus(t *testing.T) {
+	if err := checkInitialStatus(models.StatusOpen); err != nil {
+		t.Errorf("Expected open to be accepted, got %v", err)
+	}
+	for _, status := range []models.TaskStatus{models.StatusInProgress, models.StatusBlocked, models.StatusDone, models.StatusArchived} {
+		err := checkInitialStatus(status)
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Kind != KindValidation || len(svcErr.Fields) != 1 || svcErr.Fields[0].Field != "status" {
+			t.Errorf("Expected a status validation error for %s, got %v", status, err)
+		}
+	}
+}
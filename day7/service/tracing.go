@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"taskmanager/auth"
+	"taskmanager/models"
+	"taskmanager/tracing"
+)
+
+// TraceTasks records a span for every call to tasks. A nil tracer returns
+// tasks unchanged.
+func TraceTasks(tasks TaskService, tracer *tracing.Tracer) TaskService {
+	if tracer == nil {
+		return tasks
+	}
+	return &tracedTasks{next: tasks, tracer: tracer}
+}
+
+// TraceUsers records a span for every call to users.
+func TraceUsers(users UserService, tracer *tracing.Tracer) UserService {
+	if tracer == nil {
+		return users
+	}
+	return &tracedUsers{next: users, tracer: tracer}
+}
+
+// TraceAuth records a span for every call to authService.
+func TraceAuth(authService AuthService, tracer *tracing.Tracer) AuthService {
+	if tracer == nil {
+		return authService
+	}
+	return &tracedAuth{next: authService, tracer: tracer}
+}
+
+// TraceAudit records a span for every call to audit.
+func TraceAudit(audit AuditService, tracer *tracing.Tracer) AuditService {
+	if tracer == nil {
+		return audit
+	}
+	return &tracedAudit{next: audit, tracer: tracer}
+}
+
+func startSpan(ctx context.Context, tracer *tracing.Tracer, name string) (context.Context, *tracing.Span) {
+	return tracer.Start(ctx, name, tracing.KindInternal, slog.String("component", "service"))
+}
+
+type tracedTasks struct {
+	next   TaskService
+	tracer *tracing.Tracer
+}
+
+func (s *tracedTasks) CreateTask(ctx context.Context, task *models.Task) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.CreateTask")
+	defer func() { span.Finish(err) }()
+	return s.next.CreateTask(ctx, task)
+}
+
+func (s *tracedTasks) GetTask(ctx context.Context, id int) (task *models.Task, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.GetTask")
+	defer func() { span.Finish(err) }()
+	return s.next.GetTask(ctx, id)
+}
+
+func (s *tracedTasks) ListTasks(ctx context.Context, q *models.TaskQuery) (page *models.TaskPage, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.ListTasks")
+	defer func() { span.Finish(err) }()
+	return s.next.ListTasks(ctx, q)
+}
+
+func (s *tracedTasks) UpdateTask(ctx context.Context, task *models.Task) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.UpdateTask")
+	defer func() { span.Finish(err) }()
+	return s.next.UpdateTask(ctx, task)
+}
+
+func (s *tracedTasks) PatchTask(ctx context.Context, id, version int, patch *models.TaskPatch) (task *models.Task, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.PatchTask")
+	defer func() { span.Finish(err) }()
+	return s.next.PatchTask(ctx, id, version, patch)
+}
+
+func (s *tracedTasks) TransitionTask(ctx context.Context, id, version int, to models.TaskStatus) (task *models.Task, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.TransitionTask")
+	defer func() { span.Finish(err) }()
+	return s.next.TransitionTask(ctx, id, version, to)
+}
+
+func (s *tracedTasks) DeleteTask(ctx context.Context, id, version int) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.DeleteTask")
+	defer func() { span.Finish(err) }()
+	return s.next.DeleteTask(ctx, id, version)
+}
+
+func (s *tracedTasks) BatchTasks(ctx context.Context, batch *models.TaskBatch) (result *BatchResult, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.BatchTasks")
+	defer func() { span.Finish(err) }()
+	return s.next.BatchTasks(ctx, batch)
+}
+
+func (s *tracedTasks) ListTrash(ctx context.Context, userID int) (trash *models.Trash, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.ListTrash")
+	defer func() { span.Finish(err) }()
+	return s.next.ListTrash(ctx, userID)
+}
+
+func (s *tracedTasks) RestoreTask(ctx context.Context, id, version int) (task *models.Task, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "TaskService.RestoreTask")
+	defer func() { span.Finish(err) }()
+	return s.next.RestoreTask(ctx, id, version)
+}
+
+type tracedUsers struct {
+	next   UserService
+	tracer *tracing.Tracer
+}
+
+func (s *tracedUsers) CreateUser(ctx context.Context, user *models.User, password string) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "UserService.CreateUser")
+	defer func() { span.Finish(err) }()
+	return s.next.CreateUser(ctx, user, password)
+}
+
+func (s *tracedUsers) GetUser(ctx context.Context, id int) (user *models.User, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "UserService.GetUser")
+	defer func() { span.Finish(err) }()
+	return s.next.GetUser(ctx, id)
+}
+
+func (s *tracedUsers) ChangePassword(ctx context.Context, id, version int, current, next string) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "UserService.ChangePassword")
+	defer func() { span.Finish(err) }()
+	return s.next.ChangePassword(ctx, id, version, current, next)
+}
+
+func (s *tracedUsers) DeleteUser(ctx context.Context, id, version int, tasks models.TaskDisposition, reassignTo int) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "UserService.DeleteUser")
+	defer func() { span.Finish(err) }()
+	return s.next.DeleteUser(ctx, id, version, tasks, reassignTo)
+}
+
+func (s *tracedUsers) RestoreUser(ctx context.Context, id, version int) (user *models.User, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "UserService.RestoreUser")
+	defer func() { span.Finish(err) }()
+	return s.next.RestoreUser(ctx, id, version)
+}
+
+type tracedAuth struct {
+	next   AuthService
+	tracer *tracing.Tracer
+}
+
+func (s *tracedAuth) IssueAPIKey(ctx context.Context, userID int) (key string, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "AuthService.IssueAPIKey")
+	defer func() { span.Finish(err) }()
+	return s.next.IssueAPIKey(ctx, userID)
+}
+
+func (s *tracedAuth) Login(ctx context.Context, creds models.Credentials) (token *models.AccessToken, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "AuthService.Login")
+	defer func() { span.Finish(err) }()
+	return s.next.Login(ctx, creds)
+}
+
+func (s *tracedAuth) Logout(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "AuthService.Logout")
+	defer func() { span.Finish(err) }()
+	return s.next.Logout(ctx)
+}
+
+func (s *tracedAuth) Authenticate(ctx context.Context, token string) (p auth.Principal, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "AuthService.Authenticate")
+	defer func() { span.Finish(err) }()
+	return s.next.Authenticate(ctx, token)
+}
+
+type tracedAudit struct {
+	next   AuditService
+	tracer *tracing.Tracer
+}
+
+func (s *tracedAudit) ListAuditEvents(ctx context.Context, q *models.AuditQuery) (page *models.AuditPage, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "AuditService.ListAuditEvents")
+	defer func() { span.Finish(err) }()
+	return s.next.ListAuditEvents(ctx, q)
+}
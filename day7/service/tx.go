@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"taskmanager/repository"
+)
+
+// inTx runs fn as one unit of work. fn returns service errors; anything
+// else, such as a failed commit, is reported as internal.
+func inTx(ctx context.Context, tx repository.TxManager, fn func(ctx context.Context) error) error {
+	err := tx.Run(ctx, fn)
+	var svcErr *Error
+	if err == nil || errors.As(err, &svcErr) {
+		return err
+	}
+	return internalError(err)
+}
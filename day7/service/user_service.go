@@ -1,40 +1,347 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"taskmanager/auth"
 	"taskmanager/models"
 	"taskmanager/repository"
+	"time"
 )
 
 type UserService interface {
-	CreateUser(user *models.User) error
-	GetUser(id int) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User, password string) error
+	GetUser(ctx context.Context, id int) (*models.User, error)
+	ChangePassword(ctx context.Context, id, version int, current, next string) error
+	DeleteUser(ctx context.Context, id, version int, tasks models.TaskDisposition, reassignTo int) error
+	RestoreUser(ctx context.Context, id, version int) (*models.User, error)
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo    repository.UserRepository
+	taskRepo    repository.TaskRepository
+	sessionRepo repository.SessionRepository
+	auditRepo   repository.AuditRepository
+	tx          repository.TxManager
+	logger      *slog.Logger
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
+func NewUserService(userRepo repository.UserRepository, taskRepo repository.TaskRepository, sessionRepo repository.SessionRepository, auditRepo repository.AuditRepository, tx repository.TxManager, logger *slog.Logger) UserService {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		taskRepo:    taskRepo,
+		sessionRepo: sessionRepo,
+		auditRepo:   auditRepo,
+		tx:          tx,
+		logger:      logger,
 	}
 }
 
-func (s *userService) CreateUser(user *models.User) error {
-	if user.Email == "" || user.Name == "" {
-		return errors.New("name and emalil cannot be empty")
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 256
+)
+
+// CreateUser registers a user with a password. Only admins may pick the
+// role; everyone else gets the user role regardless of what they send.
+func (s *userService) CreateUser(ctx context.Context, user *models.User, password string) error {
+	if p, ok := auth.FromContext(ctx); !ok || !p.IsAdmin() || user.Role == "" {
+		user.Role = auth.RoleUser
+	}
+	user.Email = normalizeEmail(user.Email)
+
+	var fields []FieldError
+	if user.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Message: "is required"})
+	} else if f, ok := validateLength("name", user.Name); !ok {
+		fields = append(fields, f)
+	}
+	if f, ok := validateEmail(user.Email); !ok {
+		fields = append(fields, f)
+	}
+	if f, ok := validatePassword("password", password); !ok {
+		fields = append(fields, f)
+	}
+	if user.Role != auth.RoleUser && user.Role != auth.RoleAdmin {
+		fields = append(fields, FieldError{Field: "role", Message: "must be user or admin"})
+	}
+	if len(fields) > 0 {
+		return validationError(fields...)
 	}
-	return s.userRepo.CreateUser(user)
-}
 
-func (s *userService) GetUser(id int) (*models.User, error) {
-	user, err := s.userRepo.GetUserByID(id)
+	hash, err := auth.HashPassword(password)
 	if err != nil {
+		return internalError(err)
+	}
+	user.PasswordHash = hash
+
+	err = inTx(ctx, s.tx, func(ctx context.Context) error {
+		err := s.userRepo.CreateUser(ctx, user)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrEmailTaken
+		}
+		if err != nil {
+			return internalError(err)
+		}
+		return recordAudit(ctx, s.auditRepo, models.AuditCreate, models.AuditEntityUser, user.ID, diff(nil, user))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "user registered", slog.Int("new_user_id", user.ID), slog.String("role", user.Role))
+	return nil
+}
+
+func (s *userService) GetUser(ctx context.Context, id int) (*models.User, error) {
+	if err := authorize(ctx, id); err != nil {
 		return nil, err
 	}
+
+	user, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, internalError(err)
+	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user, nil
 }
+
+// ChangePassword replaces a user's password and signs out all their
+// sessions. Only admins resetting someone else's password may skip the
+// current one.
+func (s *userService) ChangePassword(ctx context.Context, id, version int, current, next string) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion("user", id, version, user.Version); err != nil {
+		return err
+	}
+
+	p, _ := auth.FromContext(ctx)
+	if user.PasswordHash != "" && p.UserID == id {
+		ok, err := auth.CheckPassword(user.PasswordHash, current)
+		if err != nil {
+			return internalError(err)
+		}
+		if !ok {
+			return validationError(FieldError{Field: "current_password", Message: "is incorrect"})
+		}
+	}
+	if f, ok := validatePassword("new_password", next); !ok {
+		return validationError(f)
+	}
+
+	hash, err := auth.HashPassword(next)
+	if err != nil {
+		return internalError(err)
+	}
+	var revoked int
+	err = inTx(ctx, s.tx, func(ctx context.Context) error {
+		err := s.userRepo.UpdatePasswordHash(ctx, id, user.Version, hash)
+		if err != nil {
+			return userStorageError(err)
+		}
+		updated := *user
+		updated.Version++
+		changes := diff(user, &updated)
+		changes["password"] = models.AuditChange{}
+		if err := recordAudit(ctx, s.auditRepo, models.AuditUpdate, models.AuditEntityUser, id, changes); err != nil {
+			return err
+		}
+
+		revoked, err = s.sessionRepo.RevokeUserSessions(ctx, id, time.Now().UTC())
+		return internalError(err)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "password changed", slog.Int("target_user_id", id), slog.Int("sessions_revoked", revoked))
+	return nil
+}
+
+// DeleteUser moves a user to the trash after dealing with the tasks they
+// own: reject refuses while any remain, cascade deletes them too and
+// reassign hands them to reassignTo.
+func (s *userService) DeleteUser(ctx context.Context, id, version int, tasks models.TaskDisposition, reassignTo int) error {
+	if tasks == "" {
+		tasks = models.TasksReject
+	}
+
+	var affected int
+	now := time.Now().UTC()
+	err := inTx(ctx, s.tx, func(ctx context.Context) error {
+		user, err := s.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("user", id, version, user.Version); err != nil {
+			return err
+		}
+		owned, err := s.taskRepo.GetTasksByUserID(ctx, id)
+		if err != nil {
+			return internalError(err)
+		}
+
+		switch tasks {
+		case models.TasksReject:
+			if len(owned) > 0 {
+				return ErrUserHasTasks.withMessage(fmt.Sprintf(
+					"user %d still owns %d tasks; delete with tasks=cascade or tasks=reassign", id, len(owned)))
+			}
+		case models.TasksCascade:
+			deleted, err := s.taskRepo.DeleteTasksByUserID(ctx, id, now)
+			if err != nil {
+				return internalError(err)
+			}
+			affected = deleted
+			err = s.auditTasks(ctx, models.AuditDelete, owned, func(task *models.Task) { task.DeletedAt = &now })
+			if err != nil {
+				return err
+			}
+		case models.TasksReassign:
+			if reassignTo <= 0 || reassignTo == id {
+				return validationError(FieldError{Field: "reassign_to", Message: "must be the id of another user"})
+			}
+			if err := authorize(ctx, reassignTo); err != nil {
+				return err
+			}
+			target, err := s.userRepo.GetUserByID(ctx, reassignTo)
+			if err != nil {
+				return internalError(err)
+			}
+			if target == nil {
+				return unknownUser("reassign_to", reassignTo)
+			}
+			moved, err := s.taskRepo.ReassignTasks(ctx, id, reassignTo)
+			if err != nil {
+				return internalError(err)
+			}
+			affected = moved
+			err = s.auditTasks(ctx, models.AuditUpdate, owned, func(task *models.Task) { task.UserId = reassignTo })
+			if err != nil {
+				return err
+			}
+		default:
+			return validationError(FieldError{Field: "tasks", Message: "must be one of reject, cascade or reassign"})
+		}
+
+		if _, err := s.sessionRepo.RevokeUserSessions(ctx, id, now); err != nil {
+			return internalError(err)
+		}
+		if err := s.userRepo.DeleteUser(ctx, id, user.Version, now); err != nil {
+			return userStorageError(err)
+		}
+		deleted := *user
+		deleted.DeletedAt = &now
+		deleted.Version++
+		return recordAudit(ctx, s.auditRepo, models.AuditDelete, models.AuditEntityUser, id, diff(user, &deleted))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "user deleted", slog.Int("target_user_id", id),
+		slog.String("tasks", string(tasks)), slog.Int("tasks_affected", affected))
+	return nil
+}
+
+// RestoreUser takes a user out of the trash. Only admins may, since the
+// user can no longer sign in.
+func (s *userService) RestoreUser(ctx context.Context, id, version int) (*models.User, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetDeletedUser(ctx, id)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound.withMessage(fmt.Sprintf("user %d is not in the trash", id))
+	}
+	if err := checkVersion("user", id, version, user.Version); err != nil {
+		return nil, err
+	}
+
+	restored := *user
+	restored.DeletedAt = nil
+	restored.Version++
+	err = inTx(ctx, s.tx, func(ctx context.Context) error {
+		if err := s.userRepo.RestoreUser(ctx, id, user.Version); err != nil {
+			return userStorageError(err)
+		}
+		return recordAudit(ctx, s.auditRepo, models.AuditRestore, models.AuditEntityUser, id, diff(user, &restored))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "user restored", slog.Int("target_user_id", id))
+	return &restored, nil
+}
+
+// auditTasks records the change a bulk write such as a cascading delete
+// made to each of tasks, which change applies to a copy.
+func (s *userService) auditTasks(ctx context.Context, action models.AuditAction, tasks []models.Task, change func(task *models.Task)) error {
+	for _, task := range tasks {
+		after := task
+		change(&after)
+		after.Version++
+		if err := recordAudit(ctx, s.auditRepo, action, models.AuditEntityTask, task.ID, diff(&task, &after)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userStorageError covers a user deleted or changed since it was read.
+func userStorageError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repository.ErrVersionConflict):
+		return ErrVersionMismatch
+	}
+	return internalError(err)
+}
+
+// normalizeEmail makes emails comparable, so uniqueness and login do not
+// depend on how the address was typed.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validateEmail(email string) (FieldError, bool) {
+	if email == "" {
+		return FieldError{Field: "email", Message: "is required"}, false
+	}
+	if f, ok := validateLength("email", email); !ok {
+		return f, false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return FieldError{Field: "email", Message: "must be a valid email address"}, false
+	}
+	return FieldError{}, true
+}
+
+func validatePassword(field, password string) (FieldError, bool) {
+	switch {
+	case password == "":
+		return FieldError{Field: field, Message: "is required"}, false
+	case len(password) < minPasswordLength:
+		return FieldError{Field: field, Message: fmt.Sprintf("must be at least %d characters", minPasswordLength)}, false
+	case len(password) > maxPasswordLength:
+		return FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", maxPasswordLength)}, false
+	}
+	return FieldError{}, true
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"strings"
+	"taskmanager/models"
+	"testing"
+)
+
+func TestValidationLimitsTextToColumnSize(t *testing.T) {
+	task := &models.Task{Title: strings.Repeat("é", maxTextLength), UserId: 1, Status: models.StatusOpen, Priority: models.PriorityMedium}
+	if err := validateTask(task); err != nil {
+		t.Errorf("Expected a title of %d characters to pass, got %v", maxTextLength, err)
+	}
+	task.Title += "x"
+	err, _ := validateTask(task).(*Error)
+	if err == nil || len(err.Fields) != 1 || err.Fields[0].Field != "title" {
+		t.Errorf("Expected the overlong title to be rejected, got %+v", err)
+	}
+
+	if _, ok := validateEmail(strings.Repeat("a", maxTextLength) + "@example.com"); ok {
+		t.Error("Expected an overlong email to be rejected")
+	}
+}
@@ -0,0 +1,16 @@
+package service
+
+import "fmt"
+
+// AnyVersion stands for If-Match: *, which accepts whatever version is
+// stored.
+const AnyVersion = 0
+
+// checkVersion compares the version a client sent in If-Match with the
+// stored one.
+func checkVersion(resource string, id, want, have int) error {
+	if want == AnyVersion || want == have {
+		return nil
+	}
+	return ErrVersionMismatch.withMessage(fmt.Sprintf("%s %d is at version %d, not %d", resource, id, have, want))
+}
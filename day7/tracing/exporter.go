@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Exporter receives spans as they end. Export is called on the request
+// path, so implementations should be quick and must be safe for concurrent
+// use.
+type Exporter interface {
+	Export(span SpanData)
+}
+
+// WriterExporter writes one JSON object per span to a writer, for reading
+// traces offline or piping them to a collector.
+type WriterExporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+	err    error
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+// OpenFileExporter appends spans to the file at path, creating it if needed.
+func OpenFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	e := NewWriterExporter(f)
+	e.closer = f
+	return e, nil
+}
+
+func (e *WriterExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.enc.Encode(span); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// Close closes the file opened by OpenFileExporter and reports the first
+// write that failed, if any.
+func (e *WriterExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.err
+	if e.closer != nil {
+		if closeErr := e.closer.Close(); err == nil {
+			err = closeErr
+		}
+		e.closer = nil
+	}
+	return err
+}
+
+// Recorder keeps spans in memory, mainly for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *Recorder) Export(span SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (r *Recorder) Spans() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SpanData(nil), r.spans...)
+}
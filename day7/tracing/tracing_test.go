@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		sc, ok := ParseTraceparent(tt.value)
+		if ok != tt.valid {
+			t.Errorf("%q: expected valid=%v, got %v", tt.value, tt.valid, ok)
+			continue
+		}
+		if ok && sc.Sampled != tt.sampled {
+			t.Errorf("%q: expected sampled=%v", tt.value, tt.sampled)
+		}
+		if ok && tt.value[:2] == "00" && sc.Traceparent() != tt.value {
+			t.Errorf("%q: round trip gave %q", tt.value, sc.Traceparent())
+		}
+	}
+}
+
+func TestSpansFormATree(t *testing.T) {
+	rec := &Recorder{}
+	tracer := NewTracer(rec)
+
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.Start(Extract(context.Background(), h), "GET /tasks", KindServer)
+	childCtx, child := tracer.Start(ctx, "tasks.ListTasks", KindInternal, slog.String("component", "repository"))
+	child.Finish(errors.New("boom"))
+	root.End()
+	root.End()
+
+	out := http.Header{}
+	Inject(childCtx, out)
+	if !strings.HasPrefix(out.Get(TraceparentHeader), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+child.SpanContext().SpanID.String()) {
+		t.Errorf("Expected the child span to be injected, got %q", out.Get(TraceparentHeader))
+	}
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", spans)
+	}
+	c, r := spans[0], spans[1]
+	if r.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || r.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the root to continue the remote trace, got %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID {
+		t.Errorf("Expected the child under the root, got %+v", c)
+	}
+	if c.Status != "error" || c.StatusMessage != "boom" || c.Attributes["component"] != "repository" {
+		t.Errorf("Unexpected child span %+v", c)
+	}
+	if r.Status != "ok" || r.End.Before(r.Start) {
+		t.Errorf("Unexpected root span %+v", r)
+	}
+}
+
+func TestUnsampledTracesAreNotExported(t *testing.T) {
+	rec := &Recorder{}
+	tracer := NewTracer(rec)
+
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := tracer.Start(Extract(context.Background(), h), "GET /tasks", KindServer)
+	_, child := tracer.Start(ctx, "SELECT", KindClient)
+	child.End()
+	span.End()
+
+	if len(rec.Spans()) != 0 {
+		t.Errorf("Expected nothing exported, got %+v", rec.Spans())
+	}
+	if sc := child.SpanContext(); sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the unsampled trace to propagate, got %+v", sc)
+	}
+}
+
+func TestNilTracerIsANoOp(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "x", KindInternal)
+	span.SetAttributes(slog.Int("n", 1))
+	span.Finish(errors.New("ignored"))
+	if SpanFromContext(ctx) != nil {
+		t.Error("Expected no span in the context")
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exporter, err := OpenFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := NewTracer(exporter)
+	for _, name := range []string{"a", "b"} {
+		_, span := tracer.Start(context.Background(), name, KindInternal)
+		span.End()
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per span, got %s", data)
+	}
+	var span SpanData
+	if err := json.Unmarshal(lines[1], &span); err != nil || span.Name != "b" || len(span.TraceID) != 32 {
+		t.Errorf("Unexpected span %s (%v)", lines[1], err)
+	}
+}